@@ -0,0 +1,229 @@
+package duplicate_file_handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// archiveSeparator divides the archive path from the member path in virtual paths like bundle.zip!/dir/file.txt.
+const archiveSeparator = "!/"
+
+func isArchive(path string) bool {
+	name := strings.ToLower(path)
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar") ||
+		strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+func isArchiveMember(path string) bool {
+	return strings.Contains(path, archiveSeparator)
+}
+
+func splitArchivePath(path string) (string, string) {
+	archive, member, _ := strings.Cut(path, archiveSeparator)
+	return archive, member
+}
+
+// walkArchive calls fn with the virtual path and file info of every regular file inside the archive.
+func walkArchive(fsys fs.FS, archive string, fn func(path string, info fs.FileInfo)) error {
+	return eachMember(fsys, archive, func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) error {
+		fn(path, info)
+		return nil
+	})
+}
+
+// eachMember calls fn with the virtual path, file info and an opener of every regular file
+// inside the archive in a single pass. The opener is only valid until fn returns.
+func eachMember(fsys fs.FS, archive string, fn func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) error) error {
+	f, err := fsys.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		r, err := newZipReader(f)
+		if err != nil {
+			return err
+		}
+		for _, zf := range r.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			if err := fn(archive+archiveSeparator+zf.Name, zf.FileInfo(), zf.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tr, err := newTarReader(archive, f)
+	if err != nil {
+		return err
+	}
+	open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeReg {
+			if err := fn(archive+archiveSeparator+header.Name, header.FileInfo(), open); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openFile opens a loose file or, for virtual paths, the archive member it points to.
+func openFile(fsys fs.FS, path string) (io.ReadCloser, error) {
+	if !isArchiveMember(path) {
+		return fsys.Open(path)
+	}
+	archive, member := splitArchivePath(path)
+	f, err := fsys.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		r, err := newZipReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		for _, zf := range r.File {
+			if zf.Name == member {
+				rc, err := zf.Open()
+				if err != nil {
+					f.Close()
+					return nil, err
+				}
+				return archiveMember{rc, f}, nil
+			}
+		}
+		f.Close()
+		return nil, fmt.Errorf("%s: member not found", path)
+	}
+
+	tr, err := newTarReader(archive, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			f.Close()
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s: member not found", path)
+			}
+			return nil, err
+		}
+		if header.Name == member {
+			return archiveMember{io.NopCloser(tr), f}, nil
+		}
+	}
+}
+
+// readFiles calls fn with the content of every file, reading each archive once for all
+// of its members instead of seeking every member from the start of a tar again. Loose
+// files come first in the given order, then the members archive by archive. The reader
+// is only valid until fn returns.
+func readFiles(fsys fs.FS, files []File, fn func(file File, r io.Reader) error) error {
+	var archives []string
+	members := make(map[string]map[string]File)
+	for _, file := range files {
+		if !isArchiveMember(file.Path) {
+			f, err := fsys.Open(file.Path)
+			if err != nil {
+				return err
+			}
+			err = fn(file, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		archive, _ := splitArchivePath(file.Path)
+		if members[archive] == nil {
+			archives = append(archives, archive)
+			members[archive] = make(map[string]File)
+		}
+		members[archive][file.Path] = file
+	}
+
+	for _, archive := range archives {
+		wanted := members[archive]
+		err := eachMember(fsys, archive, func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) error {
+			file, ok := wanted[path]
+			if !ok {
+				return nil
+			}
+			// Only the first of several members with the same name is read, like openFile does.
+			delete(wanted, path)
+			r, err := open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return fn(file, r)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newZipReader reads the archive in place when the file supports random access
+// and falls back to buffering it in memory otherwise.
+func newZipReader(f fs.File) (*zip.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		return zip.NewReader(ra, info.Size())
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(content), int64(len(content)))
+}
+
+func newTarReader(archive string, f io.Reader) (*tar.Reader, error) {
+	name := strings.ToLower(archive)
+	if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		return tar.NewReader(gz), nil
+	}
+	return tar.NewReader(f), nil
+}
+
+// archiveMember closes the archive together with the member reader.
+type archiveMember struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+func (m archiveMember) Close() error {
+	err := m.ReadCloser.Close()
+	if closeErr := m.archive.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package duplicate_file_handler
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":      {Data: []byte("loose")},
+		"backup.tgz": {Data: tarGzArchive(t, map[string]string{"p.txt": "first", "q.txt": "second", "r.txt": "unused"})},
+		"bundle.zip": {Data: zipArchive(t, map[string]string{"x.txt": "zipped"})},
+	}
+	files := []File{{Path: "backup.tgz!/q.txt"}, {Path: "bundle.zip!/x.txt"}, {Path: "a.txt"}, {Path: "backup.tgz!/p.txt"}}
+
+	got := make(map[string]string)
+	err := readFiles(fsys, files, func(file File, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		got[file.Path] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.txt":             "loose",
+		"backup.tgz!/p.txt": "first",
+		"backup.tgz!/q.txt": "second",
+		"bundle.zip!/x.txt": "zipped",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
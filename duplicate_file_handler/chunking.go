@@ -0,0 +1,168 @@
+package duplicate_file_handler
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Chunk size bounds of the content-defined chunker. Boundaries are placed where the
+// gear rolling hash has its top bits clear, giving chunks of about avgChunkSize.
+const (
+	minChunkSize = 2 * 1024
+	avgChunkSize = 8 * 1024
+	maxChunkSize = 64 * 1024
+	chunkMask    = uint64(avgChunkSize-1) << (64 - 13)
+)
+
+// maxChunkOwners leaves chunks found in more files than this, like runs of zeros or
+// common headers, out of the pair overlaps. Their pairs grow with the square of the owners
+// and say little about which files are related.
+const maxChunkOwners = 64
+
+var gearTable = generateSeeds(256)
+
+type chunkID [sha256.Size]byte
+
+// ChunkStats describes how much of the scanned files block-level dedup could save.
+type ChunkStats struct {
+	Files        int
+	Chunks       int
+	UniqueChunks int
+	TotalBytes   int64
+	UniqueBytes  int64
+	Pairs        []PairOverlap
+}
+
+// PairOverlap is the content two files share, counted once per distinct chunk.
+type PairOverlap struct {
+	A, B        string
+	SharedBytes int64
+	// SharedRatio is SharedBytes relative to the smaller file.
+	SharedRatio float64
+}
+
+// ChunkOverlap splits every file of at least minSize bytes into content-defined chunks
+// and returns the shared chunks across the tree, with the limit file pairs sharing the most.
+// Pairs only count chunks shared by at most maxChunkOwners files.
+func (s *Scanner) ChunkOverlap(minSize int64, limit int) (ChunkStats, error) {
+	var stats ChunkStats
+	files, err := s.Walk()
+	if err != nil {
+		return stats, err
+	}
+
+	var paths []string
+	var sizes []int64
+	chunkSizes := make(map[chunkID]int64)
+	chunkFiles := make(map[chunkID][]int)
+	var chunked []File
+	for _, size := range s.Sizes(files) {
+		if size >= minSize && size > 0 {
+			chunked = append(chunked, files[size]...)
+		}
+	}
+	err = readFiles(s.options.FS, chunked, func(file File, r io.Reader) error {
+		index := len(paths)
+		paths = append(paths, file.Path)
+		sizes = append(sizes, file.Size)
+		seen := make(map[chunkID]bool)
+		return chunkContent(r, func(chunk []byte) {
+			id := chunkID(sha256.Sum256(chunk))
+			stats.Chunks++
+			stats.TotalBytes += int64(len(chunk))
+			if _, ok := chunkSizes[id]; !ok {
+				chunkSizes[id] = int64(len(chunk))
+				stats.UniqueBytes += int64(len(chunk))
+			}
+			if !seen[id] {
+				seen[id] = true
+				chunkFiles[id] = append(chunkFiles[id], index)
+			}
+		})
+	})
+	if err != nil {
+		return stats, err
+	}
+	stats.Files = len(paths)
+	stats.UniqueChunks = len(chunkSizes)
+
+	shared := make(map[[2]int]int64)
+	for id, owners := range chunkFiles {
+		if len(owners) > maxChunkOwners {
+			continue
+		}
+		for a := 0; a < len(owners); a++ {
+			for b := a + 1; b < len(owners); b++ {
+				shared[[2]int{owners[a], owners[b]}] += chunkSizes[id]
+			}
+		}
+	}
+	for pair, bytes := range shared {
+		smaller := min(sizes[pair[0]], sizes[pair[1]])
+		stats.Pairs = append(stats.Pairs, PairOverlap{paths[pair[0]], paths[pair[1]], bytes, float64(bytes) / float64(smaller)})
+	}
+	sort.Slice(stats.Pairs, func(i, j int) bool {
+		if stats.Pairs[i].SharedBytes != stats.Pairs[j].SharedBytes {
+			return stats.Pairs[i].SharedBytes > stats.Pairs[j].SharedBytes
+		}
+		if stats.Pairs[i].A != stats.Pairs[j].A {
+			return stats.Pairs[i].A < stats.Pairs[j].A
+		}
+		return stats.Pairs[i].B < stats.Pairs[j].B
+	})
+	if len(stats.Pairs) > limit {
+		stats.Pairs = stats.Pairs[:limit]
+	}
+	return stats, nil
+}
+
+// chunkContent splits the stream with a gear rolling hash and calls fn with every chunk.
+// The slice passed to fn is only valid during the call.
+func chunkContent(r io.Reader, fn func(chunk []byte)) error {
+	br := bufio.NewReaderSize(r, 256*1024)
+	chunk := make([]byte, 0, maxChunkSize)
+	var hash uint64
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk = append(chunk, b)
+		hash = hash<<1 + gearTable[b]
+		if len(chunk) >= maxChunkSize || (len(chunk) >= minChunkSize && hash&chunkMask == 0) {
+			fn(chunk)
+			chunk = chunk[:0]
+			hash = 0
+		}
+	}
+	if len(chunk) > 0 {
+		fn(chunk)
+	}
+	return nil
+}
+
+func chunkOverlapReport() {
+	request := createCollectingRequest()
+	var minSize int64
+	fmt.Println("Enter the minimum file size in bytes:")
+	if _, err := fmt.Scanln(&minSize); err != nil {
+		exitProgram(1, err.Error())
+	}
+	stats, err := NewScanner(request.options()).ChunkOverlap(minSize, reportLimit)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	fmt.Printf("Files: %d, chunks: %d, unique chunks: %d\n", stats.Files, stats.Chunks, stats.UniqueChunks)
+	fmt.Printf("Total: %d bytes, unique: %d bytes, block-level dedup would save %d bytes\n",
+		stats.TotalBytes, stats.UniqueBytes, stats.TotalBytes-stats.UniqueBytes)
+	for _, pair := range stats.Pairs {
+		fmt.Printf("%d bytes shared (%.0f%%)\n  %s\n  %s\n", pair.SharedBytes, pair.SharedRatio*100,
+			displayPath(request.folder, pair.A), displayPath(request.folder, pair.B))
+	}
+}
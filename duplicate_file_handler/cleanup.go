@@ -0,0 +1,148 @@
+package duplicate_file_handler
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// DefaultJunkPatterns are file name patterns, in path.Match syntax, of files that are safe to remove.
+var DefaultJunkPatterns = []string{"Thumbs.db", "desktop.ini", ".DS_Store", "*~"}
+
+// CleanupOptions selects what FindCleanup looks for.
+type CleanupOptions struct {
+	EmptyFiles bool
+	// EmptyDirs finds directories that are empty or would become empty once the other
+	// selected categories are removed.
+	EmptyDirs    bool
+	JunkPatterns []string
+}
+
+type Cleanup struct {
+	EmptyFiles []string
+	Junk       []File
+	// EmptyDirs is ordered children first, so it can be removed in order.
+	EmptyDirs []string
+}
+
+// FindCleanup lists zero-byte files, junk files and empty directories. Zero-byte files
+// are not reported by Duplicates, since they all share the same content.
+func (s *Scanner) FindCleanup(options CleanupOptions) (Cleanup, error) {
+	var cleanup Cleanup
+	_, err := s.findCleanup(".", options, &cleanup)
+	return cleanup, err
+}
+
+// findCleanup walks dir and reports whether it is left empty after the cleanup.
+func (s *Scanner) findCleanup(dir string, options CleanupOptions, cleanup *Cleanup) (bool, error) {
+	entries, err := fs.ReadDir(s.options.FS, dir)
+	if err != nil {
+		return false, err
+	}
+	empty := true
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if name == QuarantineDir {
+				empty = false
+				continue
+			}
+			childEmpty, err := s.findCleanup(name, options, cleanup)
+			if err != nil {
+				return false, err
+			}
+			if !childEmpty || !options.EmptyDirs {
+				empty = false
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			empty = false
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case isJunk(entry.Name(), options.JunkPatterns):
+			cleanup.Junk = append(cleanup.Junk, File{name, info.Size(), info.ModTime()})
+		case info.Size() == 0 && options.EmptyFiles:
+			cleanup.EmptyFiles = append(cleanup.EmptyFiles, name)
+		default:
+			empty = false
+		}
+	}
+	if empty && dir != "." && options.EmptyDirs {
+		cleanup.EmptyDirs = append(cleanup.EmptyDirs, dir)
+	}
+	return empty, nil
+}
+
+func isJunk(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan builds the actions removing everything found, files before the directories holding them.
+// Empty directories hold nothing worth keeping, so they are always deleted.
+func (c Cleanup) Plan(kind ActionKind) Plan {
+	var plan Plan
+	for _, file := range c.EmptyFiles {
+		plan.Actions = append(plan.Actions, Action{Kind: kind, Path: file})
+	}
+	for _, file := range c.Junk {
+		plan.Actions = append(plan.Actions, Action{Kind: kind, Path: file.Path, Size: file.Size})
+	}
+	for _, dir := range c.EmptyDirs {
+		plan.Actions = append(plan.Actions, Action{Kind: Delete, Path: dir})
+	}
+	return plan
+}
+
+func cleanupTree() {
+	root, fsys := readDirectory()
+	options := CleanupOptions{
+		EmptyFiles: yesOrNoQuestion("Look for empty files?"),
+		EmptyDirs:  yesOrNoQuestion("Look for empty directories?"),
+	}
+	if yesOrNoQuestion("Look for junk files (" + fmt.Sprint(DefaultJunkPatterns) + ")?") {
+		options.JunkPatterns = DefaultJunkPatterns
+	}
+	cleanup, err := NewScanner(Options{FS: fsys}).FindCleanup(options)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	printPaths("Empty files", cleanup.EmptyFiles, root)
+	var junk []string
+	for _, file := range cleanup.Junk {
+		junk = append(junk, file.Path)
+	}
+	printPaths("Junk files", junk, root)
+	printPaths("Empty directories", cleanup.EmptyDirs, root)
+
+	plan := cleanup.Plan(Quarantine)
+	if len(plan.Actions) == 0 {
+		exitProgram(0)
+	}
+	if !yesOrNoQuestion(fmt.Sprintf("Move them to %s?", displayPath(root, QuarantineDir))) {
+		if !yesOrNoQuestion("Delete them?") {
+			exitProgram(0)
+		}
+		plan = cleanup.Plan(Delete)
+	}
+	result, err := Apply(fsys, plan)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	// Empty files and directories have no bytes, so the plan kind decides the message.
+	if plan.Actions[0].Kind == Quarantine {
+		fmt.Printf("Moved %d entries, %d bytes to %s\n", len(result.Applied), result.QuarantinedBytes, displayPath(root, QuarantineDir))
+		return
+	}
+	fmt.Printf("Cleaned up %d entries, %d bytes\n", len(result.Applied), result.FreedBytes)
+}
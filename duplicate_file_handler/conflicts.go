@@ -0,0 +1,108 @@
+package duplicate_file_handler
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	copyPrefix = regexp.MustCompile(`^(copy of )+`)
+	copySuffix = regexp.MustCompile(`( - copy( \(\d+\))?| \(\d+\)|[ _-]copy( ?\d+)?)+$`)
+)
+
+// NameConflict is a set of files with the same normalized name but different content.
+type NameConflict struct {
+	Name  string
+	Files []ConflictFile
+}
+
+type ConflictFile struct {
+	File
+	Hash string
+}
+
+// NameConflicts groups files by normalized name, so "report.txt", "Report (1).txt" and
+// "Copy of report.txt" end up together, and returns the groups whose content differs.
+// Files are ordered newest first.
+func (s *Scanner) NameConflicts() ([]NameConflict, error) {
+	files, err := s.Walk()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]File)
+	for _, sizeFiles := range files {
+		for _, file := range sizeFiles {
+			name := normalizeName(path.Base(file.Path))
+			byName[name] = append(byName[name], file)
+		}
+	}
+	var candidates []File
+	for _, sameName := range byName {
+		if len(sameName) > 1 {
+			candidates = append(candidates, sameName...)
+		}
+	}
+	s.hashMembers(context.Background(), candidates)
+
+	var conflicts []NameConflict
+	for name, sameName := range byName {
+		if len(sameName) < 2 {
+			continue
+		}
+		conflict := NameConflict{Name: name}
+		hashes := make(map[string]bool)
+		for _, file := range sameName {
+			hash, err := s.hashFile(context.Background(), file.Path)
+			if err != nil {
+				return nil, err
+			}
+			hashes[hash] = true
+			conflict.Files = append(conflict.Files, ConflictFile{file, hash})
+		}
+		if len(hashes) < 2 {
+			continue
+		}
+		sort.Slice(conflict.Files, func(i, j int) bool {
+			return conflict.Files[i].ModTime.After(conflict.Files[j].ModTime)
+		})
+		conflicts = append(conflicts, conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Name < conflicts[j].Name })
+	return conflicts, nil
+}
+
+// normalizeName lowercases the name and strips copy markers added by file managers.
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		return name
+	}
+	stem = copyPrefix.ReplaceAllString(stem, "")
+	stem = copySuffix.ReplaceAllString(stem, "")
+	return stem + ext
+}
+
+func nameConflictReport() {
+	request := createCollectingRequest()
+	conflicts, err := NewScanner(request.options()).NameConflicts()
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	if len(conflicts) == 0 {
+		fmt.Println("No name conflicts found")
+		return
+	}
+	for _, conflict := range conflicts {
+		fmt.Printf("Name: %s\n", conflict.Name)
+		for _, file := range conflict.Files {
+			fmt.Printf("%s %10d bytes  %s  %s\n", file.Hash, file.Size, file.ModTime.Format(time.DateTime), displayPath(request.folder, file.Path))
+		}
+	}
+}
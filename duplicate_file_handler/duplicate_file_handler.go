@@ -1,79 +1,169 @@
 package duplicate_file_handler
 
 import (
-	"bufio"
-	"crypto/md5"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"sort"
-	"strconv"
+	"os/signal"
 	"strings"
 )
 
-type FilesBySize map[int64][]string
+type FilesBySize map[int64][]File
 type FileToDelete struct {
 	number int
+	group  int
+	hash   string
 	path   string
 	size   int64
 }
-type FilesByHash map[string][]FileToDelete
 type CollectingRequest struct {
-	folder  string
-	format  string
-	sorting int
+	folder   string
+	fsys     WritableFS
+	format   string
+	sorting  int
+	archives bool
+}
+
+var modes = []string{
+	"1. Find duplicates",
+	"2. Save a snapshot",
+	"3. Compare snapshots",
+	"4. Write an integrity manifest",
+	"5. Verify an integrity manifest",
+	"6. Find duplicates in a very large tree",
+	"7. Wasted space report",
+	"8. Watch for new duplicates",
+	"9. Clean up empty files, empty directories and junk",
+	"10. Same name, different content report",
+	"11. Export a hash manifest for another machine",
+	"12. Find files that exist on another machine",
+	"13. Partial overlap analysis of large files",
+	"14. Review duplicates in a terminal UI",
 }
 
 func Run() {
+	switch chooseMode() {
+	case 1:
+		findDuplicates()
+	case 2:
+		saveSnapshot()
+	case 3:
+		compareSnapshots()
+	case 4:
+		writeManifest()
+	case 5:
+		verifyManifest()
+	case 6:
+		findDuplicatesInLargeTree()
+	case 7:
+		wastedSpaceReport()
+	case 8:
+		watchDuplicates()
+	case 9:
+		cleanupTree()
+	case 10:
+		nameConflictReport()
+	case 11:
+		exportRemoteManifest()
+	case 12:
+		matchRemoteManifest()
+	case 13:
+		chunkOverlapReport()
+	case 14:
+		reviewDuplicates()
+	}
+}
 
-	var err error
-	var filesBySize = make(FilesBySize)
+func chooseMode() int {
+	fmt.Println("Modes:")
+	for _, mode := range modes {
+		fmt.Println(mode)
+	}
+	for {
+		var mode int
+		fmt.Println("Enter a mode:")
+		_, err := fmt.Scanln(&mode)
+		if err != nil {
+			exitProgram(1, err.Error())
+		}
+		if mode >= 1 && mode <= len(modes) {
+			return mode
+		}
+		fmt.Println("Wrong option")
+	}
+}
 
+func findDuplicates() {
 	request := createCollectingRequest()
+	options := request.options()
+	options.Progress = printProgress
+	scanner := NewScanner(options)
 
-	err = groupFiles(request, &filesBySize)
+	// Ctrl+C only cancels while a scan runs, so it still ends the program at the prompts.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	filesBySize, err := scanner.WalkContext(ctx)
+	stop()
+	fmt.Fprintln(os.Stderr)
+	if errors.Is(err, context.Canceled) {
+		exitProgram(1, "Scan is cancelled")
+	}
 	if err != nil {
 		exitProgram(1, err.Error())
 	}
-	sortedKeys := sortKeys(&filesBySize, request.sorting)
-	printFilesBySize(&filesBySize, sortedKeys)
+	sortedKeys := scanner.Sizes(filesBySize)
+	printFilesBySize(&filesBySize, sortedKeys, request.folder)
 
 	var duplicates *[]FileToDelete
 
 	if yesOrNoQuestion("Check for duplicates?") {
-		duplicates = processDuplicates(&filesBySize, sortedKeys)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		groups, err := scanner.DuplicatesContext(ctx, filesBySize)
+		stop()
+		fmt.Fprintln(os.Stderr)
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("Scan is cancelled")
+			if yesOrNoQuestion("Show partial results?") {
+				processDuplicates(groups, sortedKeys, request.folder)
+			}
+			exitProgram(1)
+		}
+		if err != nil {
+			exitProgram(1, err.Error())
+		}
+		duplicates = processDuplicates(groups, sortedKeys, request.folder)
 	} else {
 		exitProgram(0)
 	}
 
+	if yesOrNoQuestion("Check for similar text documents?") {
+		threshold := readSimilarityThreshold()
+		clusters, err := findSimilarDocuments(request.fsys, allFiles(&filesBySize, sortedKeys), threshold)
+		if err != nil {
+			exitProgram(1, err.Error())
+		}
+		printSimilarClusters(clusters, request.folder)
+	}
+
 	if yesOrNoQuestion("Delete files?") {
-		deleteFiles(duplicates, sortedKeys)
+		deleteFiles(request, duplicates)
 	} else {
 		exitProgram(0)
 	}
 }
 
 func createCollectingRequest() CollectingRequest {
-	var root string
 	var format string
 	var sorting int
 
-	fmt.Println("Enter the directory to check duplicates:")
-	_, err := fmt.Scanln(&root)
-	if root == "" || err != nil {
-		exitProgram(1, "Directory is not specified")
-	}
-
-	_, err = os.Stat(root)
-	if os.IsNotExist(err) {
-		exitProgram(1, "Directory does not exist")
-	}
+	root, fsys := readDirectory()
 	fmt.Println("Enter file format:")
-	_, err = fmt.Scanln(&format)
+	_, err := fmt.Scanln(&format)
 	if err != nil && err.Error() != "unexpected newline" {
 		exitProgram(1, err.Error())
 	}
+	archives := yesOrNoQuestion("Scan inside archives?")
 	fmt.Println("Size sorting options:")
 	fmt.Println("1. Descending")
 	fmt.Println("2. Ascending")
@@ -88,52 +178,73 @@ func createCollectingRequest() CollectingRequest {
 		}
 		fmt.Println("Wrong option")
 	}
-	return CollectingRequest{root, format, sorting}
+	return CollectingRequest{root, fsys, format, sorting, archives}
 }
 
-func groupFiles(request CollectingRequest, fileMap *FilesBySize) error {
-	result := *fileMap
-	err := filepath.Walk(request.folder, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			exitProgram(1, err.Error())
-		}
-		if !info.IsDir() {
-			if request.format != "" {
-				if filepath.Ext(path) != "."+request.format {
-					return nil
-				}
-			}
-			size := info.Size()
-			result[size] = append(result[size], path)
-		}
-		return nil
-	})
-	return err
-}
-
-func sortKeys(fm *FilesBySize, sorting int) []int64 {
-	keys := make([]int64, 0, len(*fm))
-	for k := range *fm {
-		keys = append(keys, k)
+func readDirectory() (string, WritableFS) {
+	var root string
+	fmt.Println("Enter the directory to check duplicates:")
+	_, err := fmt.Scanln(&root)
+	if root == "" || err != nil {
+		exitProgram(1, "Directory is not specified")
 	}
-	if sorting == 1 {
-		sort.Slice(keys, func(i, j int) bool { return keys[i] > keys[j] })
-	} else {
-		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	if _, err = os.Stat(root); os.IsNotExist(err) {
+		exitProgram(1, "Directory does not exist")
 	}
-	return keys
+	return root, NewDirFS(root)
+}
+
+func (r CollectingRequest) options() Options {
+	return Options{FS: r.fsys, Format: r.format, Descending: r.sorting == 1, Archives: r.archives, Skipped: printSkipped}
 }
 
-func printFilesBySize(fm *FilesBySize, sizes []int64) {
+func printSkipped(path string, err error) {
+	fmt.Fprintf(os.Stderr, "\rSkipping %s: %s\n", path, err)
+}
+
+func printFilesBySize(fm *FilesBySize, sizes []int64, root string) {
 	for _, size := range sizes {
 		files := (*fm)[size]
 		fmt.Printf("%d bytes\n", size)
 		for _, file := range files {
-			fmt.Println(file)
+			fmt.Println(displayPath(root, file.Path))
 		}
 	}
 }
 
+func allFiles(fm *FilesBySize, sizes []int64) []File {
+	var files []File
+	for _, size := range sizes {
+		files = append(files, (*fm)[size]...)
+	}
+	return files
+}
+
+func readSimilarityThreshold() float64 {
+	for {
+		var threshold float64
+		fmt.Println("Enter similarity threshold (0-1):")
+		_, err := fmt.Scanln(&threshold)
+		if err != nil {
+			exitProgram(1, err.Error())
+		}
+		if threshold > 0 && threshold <= 1 {
+			return threshold
+		}
+		fmt.Println("Wrong option")
+	}
+}
+
+func readFileName(question string) string {
+	var name string
+	fmt.Println(question)
+	_, err := fmt.Scanln(&name)
+	if name == "" || err != nil {
+		exitProgram(1, "File is not specified")
+	}
+	return name
+}
+
 func yesOrNoQuestion(question string) bool {
 	for {
 		var checkForDuplicates string
@@ -149,106 +260,98 @@ func yesOrNoQuestion(question string) bool {
 	}
 }
 
-func processDuplicates(fm *FilesBySize, sortedKeys []int64) *[]FileToDelete {
+func processDuplicates(groups []DuplicateGroup, sortedKeys []int64, root string) *[]FileToDelete {
 	counter := 1
+	groupNumber := 0
 	var result []FileToDelete
 	for _, size := range sortedKeys {
-		filesBySize := (*fm)[size]
-		filesByHash := getDuplicates(filesBySize)
-		var duplicatesForPrinting = make(FilesByHash)
-		for hash, files := range filesByHash {
-			var filesToDelete []FileToDelete
-			for _, filePath := range files {
-				toDelete := FileToDelete{counter, filePath, size}
+		fmt.Printf("%d bytes\n", size)
+		for _, group := range groups {
+			if group.Size != size {
+				continue
+			}
+			groupNumber++
+			fmt.Printf("Hash: %s (group %d)\n", group.Hash, groupNumber)
+			for _, file := range group.Files {
+				toDelete := FileToDelete{counter, groupNumber, group.Hash, file.Path, size}
 				counter++
-				filesToDelete = append(filesToDelete, toDelete)
+				fmt.Printf("%d. %s\n", toDelete.number, displayPath(root, toDelete.path))
+				result = append(result, toDelete)
 			}
-			duplicatesForPrinting[hash] = append(duplicatesForPrinting[hash], filesToDelete...)
-			result = append(result, filesToDelete...)
 		}
-		printDuplicates(&duplicatesForPrinting, size)
 	}
 	return &result
 }
 
-func getDuplicates(filePaths []string) map[string][]string {
-	var filesByHash = make(map[string][]string)
-	for _, fileName := range filePaths {
-		hash := getHash(fileName)
-		filesByHash[hash] = append(filesByHash[hash], fileName)
+func deleteFiles(request CollectingRequest, duplicates *[]FileToDelete) {
+	if len(*duplicates) == 0 {
+		exitProgram(0, "No duplicates to delete")
 	}
-	for hash, files := range filesByHash {
-		if len(files) == 1 {
-			delete(filesByHash, hash)
+	var plan Plan
+	for len(plan.Actions) == 0 {
+		var selected []FileToDelete
+		for _, file := range readSelection(*duplicates) {
+			if isArchiveMember(file.path) {
+				fmt.Printf("Cannot delete archive member: %s\n", displayPath(request.folder, file.path))
+				continue
+			}
+			selected = append(selected, file)
 		}
-	}
-	return filesByHash
-}
-
-func printDuplicates(d *FilesByHash, size int64) {
-	fmt.Printf("%d bytes\n", size)
-	for hash, files := range *d {
-		fmt.Printf("Hash: %s\n", hash)
-		for _, file := range files {
-			fmt.Printf("%d. %s\n", file.number, file.path)
+		if len(selected) == 0 {
+			continue
+		}
+		printSelectionSummary(selected, *duplicates, request.folder)
+		if !yesOrNoQuestion("Delete the selected files?") {
+			continue
+		}
+		for _, file := range selected {
+			plan.Actions = append(plan.Actions, Action{Kind: Delete, Path: file.path, Size: file.size})
 		}
 	}
-}
-
-func getHash(file string) string {
-	f, err := os.Open(file)
+	result, err := Apply(request.fsys, plan)
 	if err != nil {
 		exitProgram(1, err.Error())
 	}
-	defer f.Close()
-
-	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
-		exitProgram(1, err.Error())
-	}
-	return fmt.Sprintf("%x", h.Sum(nil))
+	fmt.Printf("Total freed up space: %d bytes\n", result.FreedBytes)
 }
 
-func deleteFiles(duplicates *[]FileToDelete, sizes []int64) {
-	nums := readIndexesToDelete()
-	freeSpace := 0
-	for _, num := range nums {
-		file := (*duplicates)[num-1]
-		err := os.Remove(file.path)
+func readSelection(files []FileToDelete) []FileToDelete {
+	for {
+		fmt.Println("Enter file numbers to delete or help:")
+		line, err := readLine()
 		if err != nil {
 			exitProgram(1, err.Error())
 		}
-		freeSpace += int(file.size)
+		if strings.TrimSpace(line) == "help" {
+			fmt.Println(selectionHelp)
+			continue
+		}
+		selected, err := parseSelection(line, files)
+		if err != nil {
+			fmt.Println("Wrong format:", err)
+			continue
+		}
+		return selected
 	}
-	fmt.Printf("Total freed up space: %d bytes\n", freeSpace)
 }
 
-func readIndexesToDelete() []int {
-	scanner := bufio.NewScanner(os.Stdin)
+// readLine reads one line from stdin without buffering, so it can be mixed with fmt.Scanln.
+func readLine() (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
 	for {
-		fmt.Println("Enter file numbers to delete:")
-		if scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				fmt.Println("Wrong format")
-				continue
-			}
-			nums := strings.Fields(line)
-			var indexes []int
-			for _, numStr := range nums {
-				num, err := strconv.Atoi(numStr)
-				if err != nil {
-					fmt.Println("Wrong format")
-					continue
-				}
-				indexes = append(indexes, num)
-			}
-			if len(indexes) > 0 {
-				return indexes
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return strings.TrimSuffix(string(line), "\r"), nil
 			}
+			line = append(line, buf[0])
 		}
-		if err := scanner.Err(); err != nil {
-			fmt.Println("Error reading input:", err)
+		if err == io.EOF && len(line) > 0 {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
 		}
 	}
 }
@@ -0,0 +1,245 @@
+package duplicate_file_handler
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WritableFS is a filesystem the handler may modify. Scanning only needs fs.FS,
+// so read-only sources like zip files or fstest.MapFS can be scanned as well.
+type WritableFS interface {
+	fs.FS
+	Remove(name string) error
+	Link(oldname, newname string) error
+	Rename(oldname, newname string) error
+	MkdirAll(name string) error
+}
+
+type dirFS struct {
+	fs.FS
+	root string
+}
+
+// NewDirFS returns the directory tree at root as a WritableFS, so a Plan built from
+// a scan of os.DirFS(root) can be applied to it.
+func NewDirFS(root string) WritableFS {
+	return dirFS{os.DirFS(root), root}
+}
+
+func (d dirFS) Remove(name string) error {
+	path, err := d.osPath("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Link replaces newname with a hard link to oldname.
+func (d dirFS) Link(oldname, newname string) error {
+	oldPath, err := d.osPath("link", oldname)
+	if err != nil {
+		return err
+	}
+	newPath, err := d.osPath("link", newname)
+	if err != nil {
+		return err
+	}
+	return linkFile(oldPath, newPath)
+}
+
+func linkFile(oldPath, newPath string) error {
+	tmpPath := newPath + ".dup-link"
+	if err := os.Link(oldPath, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (d dirFS) Rename(oldname, newname string) error {
+	oldPath, err := d.osPath("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newPath, err := d.osPath("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+func (d dirFS) MkdirAll(name string) error {
+	path, err := d.osPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, os.ModePerm)
+}
+
+func (d dirFS) osPath(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.root, filepath.FromSlash(name)), nil
+}
+
+// mountFS presents several directories as one tree, each under its own top-level name,
+// so that duplicates are found across all of them. Every directory keeps its own
+// quarantine: QuarantineDir/<name>/... is stored as <name>/QuarantineDir/....
+type mountFS struct {
+	names  []string
+	mounts map[string]dirFS
+}
+
+func newMountFS(roots []string) mountFS {
+	m := mountFS{mounts: make(map[string]dirFS)}
+	for _, root := range roots {
+		base := filepath.Base(filepath.Clean(root))
+		if !fs.ValidPath(base) || base == "." || base == QuarantineDir {
+			base = "root"
+		}
+		name := base
+		for i := 2; m.mounts[name].root != ""; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		m.names = append(m.names, name)
+		m.mounts[name] = dirFS{os.DirFS(root), root}
+	}
+	return m
+}
+
+// split returns the directory holding name and the path of name inside it.
+func (m mountFS) split(op string, name string) (dirFS, string, error) {
+	if !fs.ValidPath(name) {
+		return dirFS{}, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	first, rest, _ := strings.Cut(name, "/")
+	if first == QuarantineDir {
+		mount, inner, _ := strings.Cut(rest, "/")
+		if d, ok := m.mounts[mount]; ok {
+			return d, path.Join(QuarantineDir, inner), nil
+		}
+	}
+	d, ok := m.mounts[first]
+	if !ok {
+		return dirFS{}, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if rest == "" {
+		rest = "."
+	}
+	return d, rest, nil
+}
+
+func (m mountFS) Open(name string) (fs.File, error) {
+	d, inner, err := m.split("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.Open(inner)
+}
+
+func (m mountFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return mountInfo{"."}, nil
+	}
+	d, inner, err := m.split("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(d, inner)
+}
+
+func (m mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		d, inner, err := m.split("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		return fs.ReadDir(d, inner)
+	}
+	var entries []fs.DirEntry
+	for _, name := range m.names {
+		entries = append(entries, fs.FileInfoToDirEntry(mountInfo{name}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m mountFS) Remove(name string) error {
+	d, inner, err := m.split("remove", name)
+	if err != nil {
+		return err
+	}
+	return d.Remove(inner)
+}
+
+// Link works across the mounted directories as long as they are on the same device.
+func (m mountFS) Link(oldname, newname string) error {
+	oldDir, oldInner, err := m.split("link", oldname)
+	if err != nil {
+		return err
+	}
+	newDir, newInner, err := m.split("link", newname)
+	if err != nil {
+		return err
+	}
+	oldPath, _ := oldDir.osPath("link", oldInner)
+	newPath, _ := newDir.osPath("link", newInner)
+	return linkFile(oldPath, newPath)
+}
+
+func (m mountFS) Rename(oldname, newname string) error {
+	oldDir, oldInner, err := m.split("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newDir, newInner, err := m.split("rename", newname)
+	if err != nil {
+		return err
+	}
+	oldPath, _ := oldDir.osPath("rename", oldInner)
+	newPath, _ := newDir.osPath("rename", newInner)
+	return os.Rename(oldPath, newPath)
+}
+
+func (m mountFS) MkdirAll(name string) error {
+	d, inner, err := m.split("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return d.MkdirAll(inner)
+}
+
+// osPath returns where name is on disk.
+func (m mountFS) osPath(name string) string {
+	d, inner, err := m.split("path", name)
+	if err != nil {
+		return name
+	}
+	return displayPath(d.root, inner)
+}
+
+// mountInfo describes the synthetic directories of a mountFS: its root and the mount points.
+type mountInfo struct {
+	name string
+}
+
+func (i mountInfo) Name() string       { return i.name }
+func (i mountInfo) Size() int64        { return 0 }
+func (i mountInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (i mountInfo) ModTime() time.Time { return time.Time{} }
+func (i mountInfo) IsDir() bool        { return true }
+func (i mountInfo) Sys() any           { return nil }
+
+func displayPath(root string, name string) string {
+	return filepath.Join(root, filepath.FromSlash(name))
+}
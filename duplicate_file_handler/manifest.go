@@ -0,0 +1,175 @@
+package duplicate_file_handler
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ManifestEntry is one line of a sha256sum-compatible manifest.
+type ManifestEntry struct {
+	Hash string
+	Path string
+}
+
+type VerifyResult struct {
+	Missing   []string
+	Added     []string
+	Corrupted []string
+}
+
+// Manifest returns the sha256 of every file in the tree ordered by path.
+func (s *Scanner) Manifest() ([]ManifestEntry, error) {
+	files, err := s.Walk()
+	if err != nil {
+		return nil, err
+	}
+	var entries []ManifestEntry
+	for _, sizeFiles := range files {
+		for _, file := range sizeFiles {
+			hash, err := hashFile(s.options.FS, file.Path, sha256.New())
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, ManifestEntry{hash, file.Path})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// Verify re-hashes the tree and compares it with a manifest written by Manifest.
+func (s *Scanner) Verify(entries []ManifestEntry) (VerifyResult, error) {
+	var result VerifyResult
+	files, err := s.Walk()
+	if err != nil {
+		return result, err
+	}
+	present := make(map[string]bool)
+	for _, sizeFiles := range files {
+		for _, file := range sizeFiles {
+			present[file.Path] = true
+		}
+	}
+	expected := make(map[string]bool)
+	for _, entry := range entries {
+		expected[entry.Path] = true
+		if !present[entry.Path] {
+			result.Missing = append(result.Missing, entry.Path)
+			continue
+		}
+		hash, err := hashFile(s.options.FS, entry.Path, sha256.New())
+		if err != nil {
+			return result, err
+		}
+		if hash != entry.Hash {
+			result.Corrupted = append(result.Corrupted, entry.Path)
+		}
+	}
+	for path := range present {
+		if !expected[path] {
+			result.Added = append(result.Added, path)
+		}
+	}
+	sort.Strings(result.Missing)
+	sort.Strings(result.Added)
+	sort.Strings(result.Corrupted)
+	return result, nil
+}
+
+// WriteManifest writes entries in the sha256sum format, escaping names with
+// backslashes or newlines the way sha256sum does.
+func WriteManifest(w io.Writer, entries []ManifestEntry) error {
+	for _, entry := range entries {
+		prefix, path := "", entry.Path
+		if strings.ContainsAny(path, "\\\n") {
+			prefix = "\\"
+			path = strings.ReplaceAll(path, "\\", "\\\\")
+			path = strings.ReplaceAll(path, "\n", "\\n")
+		}
+		if _, err := fmt.Fprintf(w, "%s%s  %s\n", prefix, entry.Hash, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadManifest parses sha256sum output in text or binary ("*") mode.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(text, "\\")
+		text = strings.TrimPrefix(text, "\\")
+		hash, path, ok := strings.Cut(text, " ")
+		if !ok || len(hash) != sha256.Size*2 || path == "" || (path[0] != ' ' && path[0] != '*') {
+			return nil, fmt.Errorf("manifest line %d: wrong format", line)
+		}
+		path = path[1:]
+		if escaped {
+			path = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(path)
+		}
+		entries = append(entries, ManifestEntry{strings.ToLower(hash), path})
+	}
+	return entries, scanner.Err()
+}
+
+func writeManifest() {
+	_, fsys := readDirectory()
+	path := readFileName("Enter the manifest file:")
+	entries, err := NewScanner(Options{FS: fsys}).Manifest()
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	err = WriteManifest(f, entries)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	fmt.Printf("Manifest of %d files is saved to %s\n", len(entries), path)
+}
+
+func verifyManifest() {
+	root, fsys := readDirectory()
+	path := readFileName("Enter the manifest file:")
+	f, err := os.Open(path)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	entries, err := ReadManifest(f)
+	f.Close()
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	result, err := NewScanner(Options{FS: fsys}).Verify(entries)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	printPaths("Missing files", result.Missing, root)
+	printPaths("Added files", result.Added, root)
+	printPaths("Corrupted files", result.Corrupted, root)
+	if len(result.Missing)+len(result.Added)+len(result.Corrupted) == 0 {
+		fmt.Printf("All %d files are intact\n", len(entries))
+	}
+}
+
+func printPaths(title string, paths []string, root string) {
+	fmt.Printf("%s: %d\n", title, len(paths))
+	for _, path := range paths {
+		fmt.Println(displayPath(root, path))
+	}
+}
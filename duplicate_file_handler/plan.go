@@ -0,0 +1,101 @@
+package duplicate_file_handler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+type ActionKind int
+
+const (
+	// Delete removes the file.
+	Delete ActionKind = iota
+	// Link replaces the file with a hard link to Action.Target.
+	Link
+	// Quarantine moves the file under QuarantineDir, keeping its relative path. A file
+	// quarantined earlier at the same path is kept, the new one gets a numbered name.
+	Quarantine
+)
+
+// QuarantineDir is the directory at the root of the scanned tree where quarantined
+// files are kept. Scans skip it.
+const QuarantineDir = ".dup-quarantine"
+
+type Action struct {
+	Kind   ActionKind
+	Path   string
+	Target string
+	Size   int64
+}
+
+// Plan is the list of actions to perform on the scanned tree, usually built from []DuplicateGroup.
+type Plan struct {
+	Actions []Action
+}
+
+type ApplyResult struct {
+	// Applied lists the actions performed, with Target set to the quarantine path of
+	// quarantined files.
+	Applied []Action
+	// FreedBytes counts deleted and linked files. Quarantined files still take their
+	// space and are counted in QuarantinedBytes.
+	FreedBytes       int64
+	QuarantinedBytes int64
+}
+
+var errArchiveMember = errors.New("archive members are read-only")
+
+// Apply performs the actions of the plan in order and stops at the first failure,
+// returning what has been applied so far.
+func Apply(fsys WritableFS, plan Plan) (ApplyResult, error) {
+	var result ApplyResult
+	for _, action := range plan.Actions {
+		if isArchiveMember(action.Path) || isArchiveMember(action.Target) {
+			return result, fmt.Errorf("%s: %w", action.Path, errArchiveMember)
+		}
+		var err error
+		switch action.Kind {
+		case Delete:
+			err = fsys.Remove(action.Path)
+		case Link:
+			err = fsys.Link(action.Target, action.Path)
+		case Quarantine:
+			if action.Target, err = quarantineTarget(fsys, action.Path); err == nil {
+				if err = fsys.MkdirAll(path.Dir(action.Target)); err == nil {
+					err = fsys.Rename(action.Path, action.Target)
+				}
+			}
+		default:
+			err = fmt.Errorf("%s: unknown action %d", action.Path, action.Kind)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Applied = append(result.Applied, action)
+		if action.Kind == Quarantine {
+			result.QuarantinedBytes += action.Size
+		} else {
+			result.FreedBytes += action.Size
+		}
+	}
+	return result, nil
+}
+
+// quarantineTarget returns the path of the file under QuarantineDir, adding .1, .2 and
+// so on when an earlier run already quarantined something at that path.
+func quarantineTarget(fsys fs.FS, name string) (string, error) {
+	base := path.Join(QuarantineDir, name)
+	target := base
+	for i := 1; ; i++ {
+		_, err := fs.Stat(fsys, target)
+		if errors.Is(err, fs.ErrNotExist) {
+			return target, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		target = fmt.Sprintf("%s.%d", base, i)
+	}
+}
@@ -0,0 +1,200 @@
+package duplicate_file_handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Policy is a dedup job kept in a JSON file, so that it can be reviewed and rerun
+// without answering the prompts, for example:
+//
+//	{
+//	  "roots": ["/data/photos", "/backup/photos"],
+//	  "format": "jpg",
+//	  "min_size": 1024,
+//	  "exclude": [".git", "*.tmp"],
+//	  "hash": "sha256",
+//	  "keep": "oldest",
+//	  "action": "quarantine",
+//	  "dry_run": true
+//	}
+//
+// Duplicates are found across all roots. Keep is one of KeepRules and Action is
+// delete, link or quarantine. Roots are listed by priority: keep "first" keeps the copy
+// under the earliest root, which also wins the ties of the other rules.
+type Policy struct {
+	Roots    []string `json:"roots"`
+	Format   string   `json:"format,omitempty"`
+	MinSize  int64    `json:"min_size,omitempty"`
+	MaxSize  int64    `json:"max_size,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	Archives bool     `json:"archives,omitempty"`
+	Hash     string   `json:"hash,omitempty"`
+	Keep     KeepRule `json:"keep"`
+	Action   string   `json:"action"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+}
+
+var policyActions = map[string]ActionKind{"delete": Delete, "link": Link, "quarantine": Quarantine}
+
+func LoadPolicy(name string) (Policy, error) {
+	var policy Policy
+	f, err := os.Open(name)
+	if err != nil {
+		return policy, err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("%s: %w", name, err)
+	}
+	return policy, nil
+}
+
+func (p Policy) Validate() error {
+	if len(p.Roots) == 0 {
+		return errors.New("policy has no roots")
+	}
+	for _, root := range p.Roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("root %s is not a directory", root)
+		}
+	}
+	if p.MinSize < 0 || p.MaxSize < 0 || (p.MaxSize > 0 && p.MaxSize < p.MinSize) {
+		return fmt.Errorf("wrong size range %d-%d", p.MinSize, p.MaxSize)
+	}
+	for _, pattern := range p.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("wrong exclude pattern %q", pattern)
+		}
+	}
+	if _, ok := hashAlgorithms[p.Hash]; !ok {
+		return fmt.Errorf("unknown hash algorithm %q", p.Hash)
+	}
+	if _, err := p.Keep.Keep(DuplicateGroup{}); err != nil {
+		return err
+	}
+	if _, ok := policyActions[p.Action]; !ok {
+		return fmt.Errorf("unknown action %q", p.Action)
+	}
+	return nil
+}
+
+func (p Policy) options(fsys fs.FS) Options {
+	return Options{
+		FS:       fsys,
+		Format:   p.Format,
+		Archives: p.Archives,
+		MinSize:  p.MinSize,
+		MaxSize:  p.MaxSize,
+		// Every root has its own quarantine below its mount point.
+		Exclude: append([]string{QuarantineDir}, p.Exclude...),
+		Hash:    p.Hash,
+		Skipped: printSkipped,
+	}
+}
+
+// Plan returns the policy action for every copy its keep rule does not keep. Archive
+// members are read-only, so they are neither acted on nor kept in place of a regular file.
+// The files of a group are ordered by root, in the order of Roots, and then by path.
+func (p Policy) Plan(groups []DuplicateGroup) (Plan, error) {
+	var plan Plan
+	kind := policyActions[p.Action]
+	priority := make(map[string]int)
+	for i, name := range newMountFS(p.Roots).names {
+		priority[name] = i
+	}
+	rootOf := func(file File) int {
+		name, _, _ := strings.Cut(file.Path, "/")
+		if i, ok := priority[name]; ok {
+			return i
+		}
+		return len(priority)
+	}
+	for _, group := range groups {
+		var files []File
+		for _, file := range group.Files {
+			if !isArchiveMember(file.Path) {
+				files = append(files, file)
+			}
+		}
+		if len(files) < 2 {
+			continue
+		}
+		sort.SliceStable(files, func(i, j int) bool { return rootOf(files[i]) < rootOf(files[j]) })
+		kept, err := p.Keep.Keep(DuplicateGroup{group.Hash, group.Size, files})
+		if err != nil {
+			return plan, err
+		}
+		for i, file := range files {
+			if i == kept {
+				continue
+			}
+			action := Action{Kind: kind, Path: file.Path, Size: file.Size}
+			if kind == Link {
+				action.Target = files[kept].Path
+			}
+			plan.Actions = append(plan.Actions, action)
+		}
+	}
+	return plan, nil
+}
+
+// RunPolicy loads the policy file, scans its roots and applies the resulting plan,
+// or only lists it for a dry run.
+func RunPolicy(name string, w io.Writer) error {
+	policy, err := LoadPolicy(name)
+	if err != nil {
+		return err
+	}
+	fsys := newMountFS(policy.Roots)
+	groups, err := NewScanner(policy.options(fsys)).Scan()
+	if err != nil {
+		return err
+	}
+	plan, err := policy.Plan(groups)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, action := range plan.Actions {
+		total += action.Size
+		if action.Kind == Link {
+			fmt.Fprintf(w, "%s %s -> %s\n", policy.Action, fsys.osPath(action.Path), fsys.osPath(action.Target))
+		} else {
+			fmt.Fprintf(w, "%s %s\n", policy.Action, fsys.osPath(action.Path))
+		}
+	}
+	if policy.DryRun {
+		if policyActions[policy.Action] == Quarantine {
+			fmt.Fprintf(w, "Dry run: %d files, %d bytes would be quarantined\n", len(plan.Actions), total)
+		} else {
+			fmt.Fprintf(w, "Dry run: %d files, %d bytes would be freed up\n", len(plan.Actions), total)
+		}
+		return nil
+	}
+	result, err := Apply(fsys, plan)
+	if policyActions[policy.Action] == Quarantine {
+		fmt.Fprintf(w, "Total quarantined: %d bytes\n", result.QuarantinedBytes)
+	} else {
+		fmt.Fprintf(w, "Total freed up space: %d bytes\n", result.FreedBytes)
+	}
+	return err
+}
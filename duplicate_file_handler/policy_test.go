@@ -0,0 +1,36 @@
+package duplicate_file_handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyPlanFollowsRootOrder(t *testing.T) {
+	groups := []DuplicateGroup{
+		{Hash: "ab", Size: 4, Files: []File{{Path: "backup/x", Size: 4}, {Path: "main/x", Size: 4}, {Path: "main/y.zip!/x", Size: 4}}},
+		{Hash: "cd", Size: 2, Files: []File{{Path: "backup/y", Size: 2}, {Path: "main/b/y", Size: 2}}},
+	}
+	tests := []struct {
+		keep KeepRule
+		want []string
+	}{
+		{KeepFirst, []string{"backup/x", "backup/y"}},
+		// Both paths of the second group are as long, the tie goes to the first root.
+		{KeepShortestPath, []string{"backup/x", "backup/y"}},
+		{KeepLongestPath, []string{"main/x", "backup/y"}},
+	}
+	for _, test := range tests {
+		policy := Policy{Roots: []string{"/z/main", "/a/backup"}, Keep: test.keep, Action: "delete"}
+		plan, err := policy.Plan(groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, action := range plan.Actions {
+			got = append(got, action.Path)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("keep %s removes %v, want %v", test.keep, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package duplicate_file_handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const progressInterval = 200 * time.Millisecond
+
+// Progress is a point-in-time view of a running scan passed to Options.Progress.
+type Progress struct {
+	FilesWalked int
+	BytesHashed int64
+	BytesToHash int64
+	Elapsed     time.Duration
+	hashingTime time.Duration
+}
+
+// Throughput returns the hashing speed in bytes per second.
+func (p Progress) Throughput() float64 {
+	if p.hashingTime <= 0 {
+		return 0
+	}
+	return float64(p.BytesHashed) / p.hashingTime.Seconds()
+}
+
+// ETA estimates the time left to hash the remaining candidate files.
+func (p Progress) ETA() time.Duration {
+	throughput := p.Throughput()
+	if throughput == 0 || p.BytesToHash <= p.BytesHashed {
+		return 0
+	}
+	return time.Duration(float64(p.BytesToHash-p.BytesHashed) / throughput * float64(time.Second))
+}
+
+type progressTracker struct {
+	progress     Progress
+	started      time.Time
+	hashStarted  time.Time
+	lastReported time.Time
+	report       func(Progress)
+}
+
+func (t *progressTracker) fileWalked() {
+	t.progress.FilesWalked++
+	t.maybeReport()
+}
+
+func (t *progressTracker) startHashing(bytes int64) {
+	t.progress.BytesToHash = bytes
+	t.hashStarted = time.Now()
+	t.maybeReport()
+}
+
+func (t *progressTracker) hashed(n int) {
+	t.progress.BytesHashed += int64(n)
+	t.maybeReport()
+}
+
+func (t *progressTracker) maybeReport() {
+	if t.report == nil || time.Since(t.lastReported) < progressInterval {
+		return
+	}
+	t.flush()
+}
+
+func (t *progressTracker) flush() {
+	if t.report == nil {
+		return
+	}
+	t.lastReported = time.Now()
+	t.progress.Elapsed = time.Since(t.started)
+	if !t.hashStarted.IsZero() {
+		t.progress.hashingTime = time.Since(t.hashStarted)
+	}
+	t.report(t.progress)
+}
+
+// progressReader counts hashed bytes and stops reading once the context is done.
+type progressReader struct {
+	ctx     context.Context
+	r       io.Reader
+	tracker *progressTracker
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.tracker.hashed(n)
+	return n, err
+}
+
+func printProgress(p Progress) {
+	fmt.Fprintf(os.Stderr, "\rWalked %d files, hashed %d of %d bytes, %.1f MB/s, ETA %s   ",
+		p.FilesWalked, p.BytesHashed, p.BytesToHash, p.Throughput()/1e6, p.ETA().Round(time.Second))
+}
@@ -0,0 +1,187 @@
+package duplicate_file_handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const remoteManifestHeader = "# dup-manifest v1 md5"
+
+var errRemoteHash = errors.New("hash manifests are always md5")
+
+// RemoteFile is an entry of a hash manifest exported on another machine.
+type RemoteFile struct {
+	Hash string
+	Size int64
+	Path string
+}
+
+// RemoteMatch is a local file whose content is present on the remote machine.
+type RemoteMatch struct {
+	Local  File
+	Hash   string
+	Remote []string
+}
+
+// ExportManifest writes the hash, size and relative path of every file, one per line,
+// so another machine can check which of its files already exist here.
+func (s *Scanner) ExportManifest(w io.Writer) error {
+	if s.options.Hash != "" && s.options.Hash != "md5" {
+		return errRemoteHash
+	}
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, remoteManifestHeader)
+	for _, file := range snapshot.Files {
+		fmt.Fprintf(bw, "%s %d %s\n", file.Hash, file.Size, strconv.Quote(file.Path))
+	}
+	return bw.Flush()
+}
+
+func ReadRemoteManifest(r io.Reader) ([]RemoteFile, error) {
+	var files []RemoteFile
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 {
+			if text != remoteManifestHeader {
+				return nil, fmt.Errorf("not a hash manifest")
+			}
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("manifest line %d: wrong format", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: wrong size", line)
+		}
+		path, err := strconv.Unquote(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: wrong path", line)
+		}
+		files = append(files, RemoteFile{fields[0], size, path})
+	}
+	return files, scanner.Err()
+}
+
+// MatchRemote returns the local files whose content is listed in the remote manifest.
+// Only local files with a size present in the manifest are hashed.
+func (s *Scanner) MatchRemote(remote []RemoteFile) ([]RemoteMatch, error) {
+	if s.options.Hash != "" && s.options.Hash != "md5" {
+		return nil, errRemoteHash
+	}
+	remoteSizes := make(map[int64]bool)
+	remoteByHash := make(map[string][]string)
+	for _, file := range remote {
+		remoteSizes[file.Size] = true
+		remoteByHash[file.Hash] = append(remoteByHash[file.Hash], file.Path)
+	}
+
+	files, err := s.Walk()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []File
+	for size, sizeFiles := range files {
+		if remoteSizes[size] && size != 0 {
+			candidates = append(candidates, sizeFiles...)
+		}
+	}
+	s.hashMembers(context.Background(), candidates)
+
+	var matches []RemoteMatch
+	for _, size := range s.Sizes(files) {
+		if !remoteSizes[size] || size == 0 {
+			continue
+		}
+		for _, file := range files[size] {
+			hash, err := s.hashFile(context.Background(), file.Path)
+			if err != nil {
+				return nil, err
+			}
+			if paths, ok := remoteByHash[hash]; ok {
+				matches = append(matches, RemoteMatch{file, hash, paths})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Local.Path < matches[j].Local.Path })
+	return matches, nil
+}
+
+func exportRemoteManifest() {
+	_, fsys := readDirectory()
+	path := readFileName("Enter the manifest file (.gz to compress):")
+	f, err := os.Create(path)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	err = NewScanner(Options{FS: fsys}).ExportManifest(w)
+	// Closing the gzip writer writes its footer, so the manifest is only saved after it.
+	if gz != nil {
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	fmt.Printf("Manifest is saved to %s\n", path)
+}
+
+func matchRemoteManifest() {
+	root, fsys := readDirectory()
+	path := readFileName("Enter the manifest file from the other machine:")
+	f, err := os.Open(path)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			exitProgram(1, err.Error())
+		}
+		r = gz
+	}
+	remote, err := ReadRemoteManifest(r)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	matches, err := NewScanner(Options{FS: fsys}).MatchRemote(remote)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	var total int64
+	for _, match := range matches {
+		total += match.Local.Size
+		fmt.Printf("%s\n  remote: %s\n", displayPath(root, match.Local.Path), strings.Join(match.Remote, ", "))
+	}
+	fmt.Printf("%d local files, %d bytes, already exist on the other machine\n", len(matches), total)
+}
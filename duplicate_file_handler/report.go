@@ -0,0 +1,232 @@
+package duplicate_file_handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const reportLimit = 10
+
+// Report summarizes where duplicate bytes are. In every group the first file by path is
+// treated as the original and the others as wasted copies.
+type Report struct {
+	TotalWasted   int64            `json:"total_wasted"`
+	Root          *DirectoryWaste  `json:"root"`
+	LargestGroups []DuplicateGroup `json:"largest_groups"`
+	Extensions    []ExtensionWaste `json:"extensions"`
+}
+
+type DirectoryWaste struct {
+	Path     string            `json:"path"`
+	Wasted   int64             `json:"wasted"`
+	Copies   int               `json:"copies"`
+	Children []*DirectoryWaste `json:"children,omitempty"`
+}
+
+type ExtensionWaste struct {
+	Extension string `json:"extension"`
+	Copies    int    `json:"copies"`
+	Wasted    int64  `json:"wasted"`
+}
+
+// BuildReport aggregates the groups by directory and extension and keeps the limit largest groups.
+func BuildReport(groups []DuplicateGroup, limit int) Report {
+	report := Report{Root: &DirectoryWaste{Path: "."}}
+	directories := map[string]*DirectoryWaste{".": report.Root}
+	extensions := make(map[string]*ExtensionWaste)
+
+	for _, group := range groups {
+		report.TotalWasted += wastedSpace(group)
+		for _, file := range group.Files[1:] {
+			for dir := path.Dir(file.Path); ; dir = path.Dir(dir) {
+				directory(directories, dir).add(group.Size)
+				if dir == "." {
+					break
+				}
+			}
+			ext := strings.ToLower(path.Ext(file.Path))
+			if ext == "" {
+				ext = "(none)"
+			}
+			if _, ok := extensions[ext]; !ok {
+				extensions[ext] = &ExtensionWaste{Extension: ext}
+			}
+			extensions[ext].Copies++
+			extensions[ext].Wasted += group.Size
+		}
+	}
+	report.Root.sort()
+
+	report.LargestGroups = append([]DuplicateGroup(nil), groups...)
+	sort.SliceStable(report.LargestGroups, func(i, j int) bool {
+		return wastedSpace(report.LargestGroups[i]) > wastedSpace(report.LargestGroups[j])
+	})
+	if len(report.LargestGroups) > limit {
+		report.LargestGroups = report.LargestGroups[:limit]
+	}
+
+	for _, ext := range extensions {
+		report.Extensions = append(report.Extensions, *ext)
+	}
+	sort.Slice(report.Extensions, func(i, j int) bool {
+		if report.Extensions[i].Wasted != report.Extensions[j].Wasted {
+			return report.Extensions[i].Wasted > report.Extensions[j].Wasted
+		}
+		return report.Extensions[i].Extension < report.Extensions[j].Extension
+	})
+	return report
+}
+
+// directory returns the node for dir, creating it and linking it to its parent if needed.
+func directory(directories map[string]*DirectoryWaste, dir string) *DirectoryWaste {
+	if node, ok := directories[dir]; ok {
+		return node
+	}
+	node := &DirectoryWaste{Path: dir}
+	directories[dir] = node
+	parent := directory(directories, path.Dir(dir))
+	parent.Children = append(parent.Children, node)
+	return node
+}
+
+func (d *DirectoryWaste) add(size int64) {
+	d.Wasted += size
+	d.Copies++
+}
+
+func (d *DirectoryWaste) sort() {
+	sort.Slice(d.Children, func(i, j int) bool {
+		if d.Children[i].Wasted != d.Children[j].Wasted {
+			return d.Children[i].Wasted > d.Children[j].Wasted
+		}
+		return d.Children[i].Path < d.Children[j].Path
+	})
+	for _, child := range d.Children {
+		child.sort()
+	}
+}
+
+func WriteTextReport(w io.Writer, report Report, limit int) error {
+	fmt.Fprintf(w, "Total wasted space: %d bytes\n", report.TotalWasted)
+	fmt.Fprintln(w, "Worst directories:")
+	writeDirectoryTree(w, report.Root, 0, limit)
+	fmt.Fprintln(w, "Largest duplicate groups:")
+	for _, group := range report.LargestGroups {
+		fmt.Fprintf(w, "%d bytes wasted, %d copies of %d bytes, hash %s\n", wastedSpace(group), len(group.Files), group.Size, group.Hash)
+		for _, file := range group.Files {
+			fmt.Fprintf(w, "  %s\n", file.Path)
+		}
+	}
+	fmt.Fprintln(w, "By extension:")
+	for _, ext := range report.Extensions {
+		_, err := fmt.Fprintf(w, "%s: %d copies, %d bytes\n", ext.Extension, ext.Copies, ext.Wasted)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDirectoryTree(w io.Writer, d *DirectoryWaste, depth int, limit int) {
+	fmt.Fprintf(w, "%s%s: %d bytes in %d copies\n", strings.Repeat("  ", depth), d.Path, d.Wasted, d.Copies)
+	for i, child := range d.Children {
+		if i == limit {
+			fmt.Fprintf(w, "%s... %d more\n", strings.Repeat("  ", depth+1), len(d.Children)-limit)
+			break
+		}
+		writeDirectoryTree(w, child, depth+1, limit)
+	}
+}
+
+func WriteJSONReport(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{"wasted": wastedSpace}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Duplicate files report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+details { margin-left: 1.5em; }
+</style>
+</head>
+<body>
+<h1>Duplicate files report</h1>
+<p>Total wasted space: {{.TotalWasted}} bytes</p>
+<h2>Directories</h2>
+{{template "directory" .Root}}
+<h2>Largest duplicate groups</h2>
+<table>
+<tr><th>Wasted bytes</th><th>Size</th><th>Hash</th><th>Files</th></tr>
+{{range .LargestGroups}}<tr><td>{{wasted .}}</td><td>{{.Size}}</td><td>{{.Hash}}</td><td>{{range .Files}}{{.Path}}<br>{{end}}</td></tr>
+{{end}}</table>
+<h2>By extension</h2>
+<table>
+<tr><th>Extension</th><th>Copies</th><th>Wasted bytes</th></tr>
+{{range .Extensions}}<tr><td>{{.Extension}}</td><td>{{.Copies}}</td><td>{{.Wasted}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+{{define "directory"}}<details open><summary>{{.Path}}: {{.Wasted}} bytes in {{.Copies}} copies</summary>
+{{range .Children}}{{template "directory" .}}{{end}}</details>
+{{end}}`))
+
+func WriteHTMLReport(w io.Writer, report Report) error {
+	return htmlReport.Execute(w, report)
+}
+
+func wastedSpaceReport() {
+	root, fsys := readDirectory()
+	var format string
+	for format != "text" && format != "json" && format != "html" {
+		fmt.Println("Enter report format (text, json, html):")
+		if _, err := fmt.Scanln(&format); err != nil {
+			exitProgram(1, err.Error())
+		}
+	}
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if format != "text" {
+		var err error
+		if f, err = os.Create(readFileName("Enter the report file:")); err != nil {
+			exitProgram(1, err.Error())
+		}
+		w = f
+	}
+
+	groups, err := NewScanner(Options{FS: fsys, Progress: printProgress}).Scan()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	report := BuildReport(groups, reportLimit)
+	report.Root.Path = root
+	switch format {
+	case "text":
+		err = WriteTextReport(w, report, reportLimit)
+	case "json":
+		err = WriteJSONReport(w, report)
+	case "html":
+		err = WriteHTMLReport(w, report)
+	}
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+}
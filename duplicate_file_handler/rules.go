@@ -0,0 +1,37 @@
+package duplicate_file_handler
+
+import "fmt"
+
+// KeepRule decides which file of a duplicate group is kept; the other copies are removed.
+type KeepRule string
+
+const (
+	KeepFirst        KeepRule = "first"
+	KeepOldest       KeepRule = "oldest"
+	KeepNewest       KeepRule = "newest"
+	KeepShortestPath KeepRule = "shortest-path"
+	KeepLongestPath  KeepRule = "longest-path"
+)
+
+var KeepRules = []KeepRule{KeepFirst, KeepOldest, KeepNewest, KeepShortestPath, KeepLongestPath}
+
+// Keep returns the index of the file to keep. Ties go to the file that comes first in the group.
+func (r KeepRule) Keep(group DuplicateGroup) (int, error) {
+	better := map[KeepRule]func(a, b File) bool{
+		KeepFirst:        func(a, b File) bool { return false },
+		KeepOldest:       func(a, b File) bool { return a.ModTime.Before(b.ModTime) },
+		KeepNewest:       func(a, b File) bool { return a.ModTime.After(b.ModTime) },
+		KeepShortestPath: func(a, b File) bool { return len(a.Path) < len(b.Path) },
+		KeepLongestPath:  func(a, b File) bool { return len(a.Path) > len(b.Path) },
+	}[r]
+	if better == nil {
+		return 0, fmt.Errorf("unknown keep rule %q", r)
+	}
+	kept := 0
+	for i, file := range group.Files {
+		if better(file, group.Files[kept]) {
+			kept = i
+		}
+	}
+	return kept, nil
+}
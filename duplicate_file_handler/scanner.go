@@ -0,0 +1,354 @@
+package duplicate_file_handler
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Options configures a Scanner.
+type Options struct {
+	// FS is the tree to scan, for example os.DirFS(root).
+	FS fs.FS
+	// Format limits the scan to files with this extension, given without the dot.
+	Format string
+	// Descending orders results from the largest size down.
+	Descending bool
+	// Archives makes the scan descend into zip and tar files.
+	Archives bool
+	// MinSize and MaxSize limit the scan to files in this size range, zero meaning no limit.
+	MinSize int64
+	MaxSize int64
+	// Exclude skips files and directories whose name or path matches one of these
+	// path.Match patterns.
+	Exclude []string
+	// Hash is the algorithm used to compare content: md5, the default, sha1 or sha256.
+	Hash string
+	// MaxFilesInMemory switches ScanFunc to external sorting once set, keeping at most
+	// this many walked files in memory at a time.
+	MaxFilesInMemory int
+	// SpillDir is where ScanFunc keeps its temporary run files, os.TempDir() if empty.
+	SpillDir string
+	// Progress, if set, is called periodically with the state of the scan.
+	Progress func(Progress)
+	// Skipped, if set, is called with every archive that cannot be read, and with members
+	// that fail to hash. The scan leaves their members out and goes on.
+	Skipped func(path string, err error)
+}
+
+type File struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+type DuplicateGroup struct {
+	Hash  string
+	Size  int64
+	Files []File
+}
+
+// Scanner finds files with identical content in an fs.FS without any user interaction.
+type Scanner struct {
+	options Options
+	tracker *progressTracker
+	// memberHashes holds the results of the last hashMembers call by virtual path.
+	memberHashes map[string]memberHash
+}
+
+type memberHash struct {
+	hash string
+	err  error
+}
+
+func NewScanner(options Options) *Scanner {
+	return &Scanner{options: options, tracker: &progressTracker{started: time.Now(), report: options.Progress}}
+}
+
+// Scan walks the tree and returns the groups of files with identical content.
+func (s *Scanner) Scan() ([]DuplicateGroup, error) {
+	return s.ScanContext(context.Background())
+}
+
+// ScanContext is Scan that stops when ctx is done, returning the groups completed so far
+// together with the context error.
+func (s *Scanner) ScanContext(ctx context.Context) ([]DuplicateGroup, error) {
+	files, err := s.WalkContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.DuplicatesContext(ctx, files)
+}
+
+// Walk returns every matching file in the tree grouped by size.
+func (s *Scanner) Walk() (FilesBySize, error) {
+	return s.WalkContext(context.Background())
+}
+
+func (s *Scanner) WalkContext(ctx context.Context) (FilesBySize, error) {
+	result := make(FilesBySize)
+	err := s.walkFunc(ctx, func(file File) error {
+		result[file.Size] = append(result[file.Size], file)
+		return nil
+	})
+	s.tracker.flush()
+	return result, err
+}
+
+func (s *Scanner) walkFunc(ctx context.Context, fn func(File) error) error {
+	fsys := s.options.FS
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() && (path == QuarantineDir || path != "." && s.excluded(path)) {
+			return fs.SkipDir
+		}
+		if !d.Type().IsRegular() || s.excluded(path) {
+			return nil
+		}
+		if s.options.Archives && isArchive(path) {
+			if err := s.walkMembers(path, fn); err != nil {
+				return err
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !s.matches(path, info.Size()) {
+			return nil
+		}
+		s.tracker.fileWalked()
+		return fn(File{path, info.Size(), info.ModTime()})
+	})
+}
+
+// walkMembers calls fn with the matching members of the archive. Members are only passed
+// on once the whole archive has been read, so a corrupt archive is skipped as a whole.
+func (s *Scanner) walkMembers(archive string, fn func(File) error) error {
+	var members []File
+	err := walkArchive(s.options.FS, archive, func(member string, info fs.FileInfo) {
+		if s.matches(member, info.Size()) {
+			members = append(members, File{member, info.Size(), info.ModTime()})
+		}
+	})
+	if err != nil {
+		s.skip(archive, err)
+		return nil
+	}
+	for _, member := range members {
+		s.tracker.fileWalked()
+		if err := fn(member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) skip(path string, err error) {
+	if s.options.Skipped != nil {
+		s.options.Skipped(path, err)
+	}
+}
+
+// Sizes returns the sizes present in files in the configured order.
+func (s *Scanner) Sizes(files FilesBySize) []int64 {
+	keys := make([]int64, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	if s.options.Descending {
+		sort.Slice(keys, func(i, j int) bool { return keys[i] > keys[j] })
+	} else {
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	}
+	return keys
+}
+
+// Duplicates hashes the files sharing a size and returns the groups with identical content,
+// ordered by size and then by hash, with the files of each group ordered by path.
+func (s *Scanner) Duplicates(files FilesBySize) ([]DuplicateGroup, error) {
+	return s.DuplicatesContext(context.Background(), files)
+}
+
+// DuplicatesContext is Duplicates that stops when ctx is done, returning the groups
+// of the sizes completed so far together with the context error.
+func (s *Scanner) DuplicatesContext(ctx context.Context, files FilesBySize) ([]DuplicateGroup, error) {
+	var total int64
+	for size, sizeFiles := range files {
+		if len(sizeFiles) > 1 {
+			total += size * int64(len(sizeFiles))
+		}
+	}
+	s.tracker.startHashing(total)
+	defer s.tracker.flush()
+
+	var candidates []File
+	for size, sizeFiles := range files {
+		if size > 0 && len(sizeFiles) > 1 {
+			candidates = append(candidates, sizeFiles...)
+		}
+	}
+	s.hashMembers(ctx, candidates)
+
+	var groups []DuplicateGroup
+	for _, size := range s.Sizes(files) {
+		bySize, err := s.groupByHash(ctx, size, files[size])
+		if err != nil {
+			return groups, err
+		}
+		groups = append(groups, bySize...)
+	}
+	return groups, nil
+}
+
+func (s *Scanner) groupByHash(ctx context.Context, size int64, files []File) ([]DuplicateGroup, error) {
+	// Zero-byte files trivially share their content and are handled by FindCleanup instead.
+	if size == 0 || len(files) < 2 {
+		return nil, nil
+	}
+	filesByHash := make(map[string][]File)
+	for _, file := range files {
+		hash, err := s.hashFile(ctx, file.Path)
+		if err != nil && isArchiveMember(file.Path) && ctx.Err() == nil {
+			s.skip(file.Path, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		filesByHash[hash] = append(filesByHash[hash], file)
+	}
+	var groups []DuplicateGroup
+	for hash, files := range filesByHash {
+		if len(files) == 1 {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		groups = append(groups, DuplicateGroup{hash, size, files})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Hash < groups[j].Hash })
+	return groups, nil
+}
+
+func (s *Scanner) matches(name string, size int64) bool {
+	return matchesFormat(name, s.options.Format) && !s.excluded(name) &&
+		size >= s.options.MinSize && (s.options.MaxSize == 0 || size <= s.options.MaxSize)
+}
+
+func (s *Scanner) excluded(name string) bool {
+	for _, pattern := range s.options.Exclude {
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesFormat(path string, format string) bool {
+	return format == "" || filepath.Ext(path) == "."+format
+}
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"":       md5.New,
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+func (s *Scanner) hashFile(ctx context.Context, file string) (string, error) {
+	if cached, ok := s.memberHashes[file]; ok {
+		return cached.hash, cached.err
+	}
+	f, err := openFile(s.options.FS, file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return s.hash(ctx, f)
+}
+
+func (s *Scanner) hash(ctx context.Context, r io.Reader) (string, error) {
+	newHash, ok := hashAlgorithms[s.options.Hash]
+	if !ok {
+		return "", fmt.Errorf("unknown hash algorithm %q", s.options.Hash)
+	}
+	h := newHash()
+	if _, err := io.Copy(h, &progressReader{ctx, r, s.tracker}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashMembers hashes the archive members among files ahead of hashFile, reading each
+// archive once instead of seeking every member from the start of a tar again.
+func (s *Scanner) hashMembers(ctx context.Context, files []File) {
+	wanted := make(map[string]map[string]bool)
+	for _, file := range files {
+		if !isArchiveMember(file.Path) {
+			continue
+		}
+		archive, _ := splitArchivePath(file.Path)
+		if wanted[archive] == nil {
+			wanted[archive] = make(map[string]bool)
+		}
+		wanted[archive][file.Path] = true
+	}
+
+	s.memberHashes = make(map[string]memberHash)
+	for archive, members := range wanted {
+		err := eachMember(s.options.FS, archive, func(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) error {
+			if _, done := s.memberHashes[path]; done || !members[path] {
+				return nil
+			}
+			r, err := open()
+			if err != nil {
+				s.memberHashes[path] = memberHash{"", err}
+				return nil
+			}
+			hash, err := s.hash(ctx, r)
+			r.Close()
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			s.memberHashes[path] = memberHash{hash, err}
+			return nil
+		})
+		if err != nil {
+			for path := range members {
+				if _, done := s.memberHashes[path]; !done {
+					s.memberHashes[path] = memberHash{"", err}
+				}
+			}
+		}
+	}
+}
+
+func hashFile(fsys fs.FS, file string, h hash.Hash) (string, error) {
+	f, err := openFile(fsys, file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
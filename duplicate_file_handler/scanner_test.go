@@ -0,0 +1,153 @@
+package duplicate_file_handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func groupPaths(groups []DuplicateGroup) [][]string {
+	var result [][]string
+	for _, group := range groups {
+		var paths []string
+		for _, file := range group.Files {
+			paths = append(paths, file.Path)
+		}
+		result = append(result, paths)
+	}
+	return result
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScanGroupsBySizeAndHash(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":     {Data: []byte("hello")},
+		"b.txt":     {Data: []byte("hello")},
+		"c.txt":     {Data: []byte("world")},
+		"d/e.txt":   {Data: []byte("hello world")},
+		"d/f.log":   {Data: []byte("hello world")},
+		"g.txt":     {Data: []byte("unique content")},
+		"empty.txt": {Data: nil},
+		"zero.txt":  {Data: nil},
+	}
+	tests := []struct {
+		name    string
+		options Options
+		want    [][]string
+	}{
+		{"ascending", Options{}, [][]string{{"a.txt", "b.txt"}, {"d/e.txt", "d/f.log"}}},
+		{"descending", Options{Descending: true}, [][]string{{"d/e.txt", "d/f.log"}, {"a.txt", "b.txt"}}},
+		{"sha256", Options{Hash: "sha256"}, [][]string{{"a.txt", "b.txt"}, {"d/e.txt", "d/f.log"}}},
+		{"format", Options{Format: "txt"}, [][]string{{"a.txt", "b.txt"}}},
+		{"min size", Options{MinSize: 6}, [][]string{{"d/e.txt", "d/f.log"}}},
+		{"exclude", Options{Exclude: []string{"d"}}, [][]string{{"a.txt", "b.txt"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.FS = fsys
+			groups, err := NewScanner(test.options).Scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := groupPaths(groups); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			for _, group := range groups {
+				if group.Size != group.Files[0].Size {
+					t.Errorf("group size %d, file size %d", group.Size, group.Files[0].Size)
+				}
+			}
+		})
+	}
+}
+
+func TestScanArchiveMembers(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":      {Data: []byte("hello")},
+		"bundle.zip": {Data: zipArchive(t, map[string]string{"x.txt": "hello", "y/z.txt": "other"})},
+		"other.txt":  {Data: []byte("other")},
+		"broken.zip": {Data: []byte("not a zip file")},
+		"backup.tgz": {Data: tarGzArchive(t, map[string]string{"p.txt": "world", "q.txt": "world", "r.txt": "hello"})},
+	}
+
+	groups, err := NewScanner(Options{FS: fsys}).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("without Archives got %v", groupPaths(groups))
+	}
+
+	var skipped []string
+	options := Options{FS: fsys, Archives: true, Skipped: func(path string, err error) { skipped = append(skipped, path) }}
+	groups, err = NewScanner(options).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"a.txt", "backup.tgz!/r.txt", "bundle.zip!/x.txt"},
+		{"bundle.zip!/y/z.txt", "other.txt"},
+		{"backup.tgz!/p.txt", "backup.tgz!/q.txt"},
+	}
+	if got := groupPaths(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"broken.zip"}) {
+		t.Errorf("skipped %v, want [broken.zip]", skipped)
+	}
+
+	options.Format = "txt"
+	options.Exclude = []string{"z.txt"}
+	groups, err = NewScanner(options).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{{"a.txt", "backup.tgz!/r.txt", "bundle.zip!/x.txt"}, {"backup.tgz!/p.txt", "backup.tgz!/q.txt"}}
+	if got := groupPaths(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("with format and exclude got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package duplicate_file_handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const selectionHelp = `Selection syntax, separated by spaces:
+  5          file 5
+  3-17       files 3 to 17
+  !5, !3-7   exclude files from the selection
+  group:2:rest, group:*:rest   all but the first file of group 2 or of every group,
+                               leaving out archive members, which cannot be deleted
+  group:2:all                  every file of group 2
+  hash:1a2b:rest, hash:1a2b:all  the same for the group whose hash starts with 1a2b`
+
+// parseSelection resolves a selection like "1-10 !4 group:*:rest" against the numbered
+// duplicates. Exclusions apply after all inclusions, whatever their position.
+func parseSelection(input string, files []FileToDelete) ([]FileToDelete, error) {
+	tokens := strings.Fields(input)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("selection is empty")
+	}
+	included := make(map[int]bool)
+	excluded := make(map[int]bool)
+	for _, token := range tokens {
+		target := included
+		if strings.HasPrefix(token, "!") {
+			target = excluded
+			token = token[1:]
+		}
+		numbers, err := resolveSelector(token, files)
+		if err != nil {
+			return nil, err
+		}
+		for _, number := range numbers {
+			target[number] = true
+		}
+	}
+
+	var result []FileToDelete
+	for _, file := range files {
+		if included[file.number] && !excluded[file.number] {
+			result = append(result, file)
+		}
+	}
+	return result, nil
+}
+
+func resolveSelector(token string, files []FileToDelete) ([]int, error) {
+	switch {
+	case strings.HasPrefix(token, "group:"):
+		return resolveGroupSelector(token, files, func(file FileToDelete, key string) (bool, error) {
+			if key == "*" {
+				return true, nil
+			}
+			group, err := strconv.Atoi(key)
+			if err != nil || group < 1 || group > files[len(files)-1].group {
+				return false, fmt.Errorf("%s: no such group", token)
+			}
+			return file.group == group, nil
+		})
+	case strings.HasPrefix(token, "hash:"):
+		return resolveGroupSelector(token, files, func(file FileToDelete, key string) (bool, error) {
+			return key != "" && strings.HasPrefix(file.hash, strings.ToLower(key)), nil
+		})
+	}
+
+	from, to, isRange := strings.Cut(token, "-")
+	first, err := strconv.Atoi(from)
+	if err != nil {
+		return nil, fmt.Errorf("%s: wrong format", token)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return nil, fmt.Errorf("%s: wrong format", token)
+		}
+	}
+	if first < 1 || last > len(files) {
+		return nil, fmt.Errorf("%s: out of range 1-%d", token, len(files))
+	}
+	var numbers []int
+	for number := first; number <= last; number++ {
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// resolveGroupSelector handles "group:<key>:<all|rest>" and "hash:<key>:<all|rest>".
+// The first file rest keeps is the first regular file, so a read-only archive member
+// never stands in for the kept copy.
+func resolveGroupSelector(token string, files []FileToDelete, matches func(FileToDelete, string) (bool, error)) ([]int, error) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 || (parts[2] != "all" && parts[2] != "rest") {
+		return nil, fmt.Errorf("%s: wrong format", token)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no such group", token)
+	}
+	var numbers []int
+	matched := false
+	firstOfGroup := make(map[int]bool)
+	for _, file := range files {
+		ok, err := matches(file, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		matched = true
+		if parts[2] == "rest" {
+			if isArchiveMember(file.path) {
+				continue
+			}
+			if !firstOfGroup[file.group] {
+				firstOfGroup[file.group] = true
+				continue
+			}
+		}
+		numbers = append(numbers, file.number)
+	}
+	if !matched {
+		return nil, fmt.Errorf("%s: no such group", token)
+	}
+	return numbers, nil
+}
+
+// printSelectionSummary shows what is about to be removed and warns about groups
+// that would lose every regular copy. Copies inside archives do not count, as they
+// are not files of their own.
+func printSelectionSummary(selected []FileToDelete, files []FileToDelete, root string) {
+	var total int64
+	selectedInGroup := make(map[int]int)
+	for _, file := range selected {
+		if !isArchiveMember(file.path) {
+			total += file.size
+			selectedInGroup[file.group]++
+		}
+		fmt.Printf("%d. %s\n", file.number, displayPath(root, file.path))
+	}
+	filesInGroup := make(map[int]int)
+	for _, file := range files {
+		if !isArchiveMember(file.path) {
+			filesInGroup[file.group]++
+		}
+	}
+	for _, file := range selected {
+		if selectedInGroup[file.group] > 0 && selectedInGroup[file.group] == filesInGroup[file.group] {
+			fmt.Printf("Warning: every copy in group %d is selected\n", file.group)
+			selectedInGroup[file.group] = 0
+		}
+	}
+	fmt.Printf("%d files selected, %d bytes will be freed\n", len(selected), total)
+}
@@ -0,0 +1,73 @@
+package duplicate_file_handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	files := []FileToDelete{
+		{number: 1, group: 1, hash: "1a2b", path: "a.txt", size: 5},
+		{number: 2, group: 1, hash: "1a2b", path: "b.txt", size: 5},
+		{number: 3, group: 1, hash: "1a2b", path: "c.txt", size: 5},
+		{number: 4, group: 2, hash: "ffee", path: "a/bundle.zip!/x", size: 9},
+		{number: 5, group: 2, hash: "ffee", path: "b/x", size: 9},
+		{number: 6, group: 2, hash: "ffee", path: "c/x", size: 9},
+	}
+	tests := []struct {
+		input string
+		want  []int
+		err   string
+	}{
+		{input: "5", want: []int{5}},
+		{input: "2-4", want: []int{2, 3, 4}},
+		{input: "1-6 !3", want: []int{1, 2, 4, 5, 6}},
+		{input: "!2-5 1-6", want: []int{1, 6}},
+		{input: "3 3 3-3", want: []int{3}},
+		{input: "group:1:all", want: []int{1, 2, 3}},
+		{input: "group:1:rest", want: []int{2, 3}},
+		{input: "group:2:rest", want: []int{6}},
+		{input: "group:*:rest", want: []int{2, 3, 6}},
+		{input: "group:*:all !group:2:all", want: []int{1, 2, 3}},
+		{input: "hash:1A:rest", want: []int{2, 3}},
+		{input: "hash:ff:all", want: []int{4, 5, 6}},
+		{input: "", err: "selection is empty"},
+		{input: "0", err: "0: out of range 1-6"},
+		{input: "7", err: "7: out of range 1-6"},
+		{input: "4-9", err: "4-9: out of range 1-6"},
+		{input: "5-2", err: "5-2: wrong format"},
+		{input: "x", err: "x: wrong format"},
+		{input: "!", err: ": wrong format"},
+		{input: "group:3:all", err: "group:3:all: no such group"},
+		{input: "group:1:some", err: "group:1:some: wrong format"},
+		{input: "hash:00:rest", err: "hash:00:rest: no such group"},
+	}
+	for _, test := range tests {
+		selected, err := parseSelection(test.input, files)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseSelection(%q) error = %v, want %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSelection(%q): %v", test.input, err)
+			continue
+		}
+		var got []int
+		for _, file := range selected {
+			got = append(got, file.number)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseSelectionWithoutFiles(t *testing.T) {
+	for _, input := range []string{"1", "group:1:all", "group:*:rest", "hash:ab:all"} {
+		if _, err := parseSelection(input, nil); err == nil {
+			t.Errorf("parseSelection(%q) without files succeeded", input)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package duplicate_file_handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// sniffSize bytes are checked to tell text from binary files.
+	sniffSize = 8 * 1024
+	// maxDocumentSize keeps huge logs and dumps out of the comparison.
+	maxDocumentSize = 4 * 1024 * 1024
+	shingleSize     = 5
+	signatureSize   = 100
+	lshBands        = 20
+	lshRows         = signatureSize / lshBands
+)
+
+type SimilarCluster struct {
+	similarity float64
+	files      []string
+}
+
+type signature [signatureSize]uint64
+
+var minHashSeeds = generateSeeds(signatureSize)
+
+// findSimilarDocuments groups text files whose estimated Jaccard similarity of word shingles
+// is at least threshold. Candidate pairs come from LSH banding over MinHash signatures.
+// Files above maxDocumentSize and files that do not look like text are left out.
+func findSimilarDocuments(fsys fs.FS, files []File, threshold float64) ([]SimilarCluster, error) {
+	var documents []File
+	for _, file := range files {
+		if file.Size <= maxDocumentSize {
+			documents = append(documents, file)
+		}
+	}
+	var paths []string
+	var signatures []signature
+	err := readFiles(fsys, documents, func(file File, r io.Reader) error {
+		shingles, err := documentShingles(r)
+		if err != nil || len(shingles) == 0 {
+			return err
+		}
+		paths = append(paths, file.Path)
+		signatures = append(signatures, minHash(shingles))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parent := make([]int, len(paths))
+	for i := range parent {
+		parent[i] = i
+	}
+	lowest := make(map[[2]int]float64)
+	checked := make(map[[2]int]bool)
+	for band := 0; band < lshBands; band++ {
+		buckets := make(map[string][]int)
+		for i, sig := range signatures {
+			key := bandKey(sig, band)
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, docs := range buckets {
+			for a := 0; a < len(docs); a++ {
+				for b := a + 1; b < len(docs); b++ {
+					pair := [2]int{docs[a], docs[b]}
+					if checked[pair] {
+						continue
+					}
+					checked[pair] = true
+					similarity := estimateSimilarity(signatures[pair[0]], signatures[pair[1]])
+					if similarity >= threshold {
+						lowest[pair] = similarity
+						union(parent, pair[0], pair[1])
+					}
+				}
+			}
+		}
+	}
+
+	clusters := make(map[int]*SimilarCluster)
+	for i := range paths {
+		root := find(parent, i)
+		if _, ok := clusters[root]; !ok {
+			clusters[root] = &SimilarCluster{similarity: 1}
+		}
+		clusters[root].files = append(clusters[root].files, paths[i])
+	}
+	for pair, similarity := range lowest {
+		cluster := clusters[find(parent, pair[0])]
+		if similarity < cluster.similarity {
+			cluster.similarity = similarity
+		}
+	}
+
+	var result []SimilarCluster
+	for _, cluster := range clusters {
+		if len(cluster.files) > 1 {
+			sort.Strings(cluster.files)
+			result = append(result, *cluster)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].similarity != result[j].similarity {
+			return result[i].similarity > result[j].similarity
+		}
+		return result[i].files[0] < result[j].files[0]
+	})
+	return result, nil
+}
+
+// documentShingles streams the file into word shingles. It returns none if the first
+// sniffSize bytes do not look like text, without reading the rest of the file.
+func documentShingles(f io.Reader) (map[uint64]struct{}, error) {
+	r := bufio.NewReaderSize(io.LimitReader(f, maxDocumentSize), sniffSize)
+	head, err := r.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !isText(head, len(head) == sniffSize) {
+		return nil, nil
+	}
+
+	shingles := make(map[uint64]struct{})
+	words := bufio.NewScanner(r)
+	words.Buffer(nil, maxDocumentSize)
+	words.Split(scanWords)
+	var window []string
+	for words.Scan() {
+		window = append(window, strings.ToLower(words.Text()))
+		if len(window) > shingleSize {
+			window = window[1:]
+		}
+		if len(window) == shingleSize {
+			shingles[hashString(strings.Join(window, " "))] = struct{}{}
+		}
+	}
+	if err := words.Err(); err != nil {
+		return nil, err
+	}
+	// Documents shorter than a shingle are one shingle of all their words.
+	if len(shingles) == 0 && len(window) > 0 {
+		shingles[hashString(strings.Join(window, " "))] = struct{}{}
+	}
+	return shingles, nil
+}
+
+// isText reports whether content is UTF-8 without NUL bytes. A truncated sniff may end
+// in the middle of a rune, which is allowed.
+func isText(content []byte, truncated bool) bool {
+	if bytes.IndexByte(content, 0) != -1 {
+		return false
+	}
+	if truncated {
+		for i := 0; i < utf8.UTFMax-1 && len(content) > 0 && !utf8.Valid(content); i++ {
+			content = content[:len(content)-1]
+		}
+	}
+	return utf8.Valid(content)
+}
+
+// scanWords is a bufio.SplitFunc returning runs of letters and digits.
+func scanWords(data []byte, atEOF bool) (int, []byte, error) {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	start := 0
+	for start < len(data) {
+		if !atEOF && !utf8.FullRune(data[start:]) {
+			return start, nil, nil
+		}
+		r, width := utf8.DecodeRune(data[start:])
+		if isWord(r) {
+			break
+		}
+		start += width
+	}
+	for i := start; i < len(data); {
+		if !atEOF && !utf8.FullRune(data[i:]) {
+			return start, nil, nil
+		}
+		r, width := utf8.DecodeRune(data[i:])
+		if !isWord(r) {
+			return i + width, data[start:i], nil
+		}
+		i += width
+	}
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
+
+func minHash(shingles map[uint64]struct{}) signature {
+	var sig signature
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for s := range shingles {
+		for i, seed := range minHashSeeds {
+			if h := mix(s ^ seed); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+func estimateSimilarity(a, b signature) float64 {
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(signatureSize)
+}
+
+func bandKey(sig signature, band int) string {
+	buf := make([]byte, 8*lshRows)
+	for row := 0; row < lshRows; row++ {
+		binary.LittleEndian.PutUint64(buf[row*8:], sig[band*lshRows+row])
+	}
+	return string(buf)
+}
+
+func find(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+func union(parent []int, a, b int) {
+	rootA, rootB := find(parent, a), find(parent, b)
+	if rootA != rootB {
+		parent[rootB] = rootA
+	}
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix is the splitmix64 finalizer, used to derive independent hash functions from one seed each.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func generateSeeds(n int) []uint64 {
+	seeds := make([]uint64, n)
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix(state)
+	}
+	return seeds
+}
+
+func printSimilarClusters(clusters []SimilarCluster, root string) {
+	if len(clusters) == 0 {
+		fmt.Println("No similar documents found")
+		return
+	}
+	for _, cluster := range clusters {
+		fmt.Printf("Similarity: %.0f%%\n", cluster.similarity*100)
+		for _, file := range cluster.files {
+			fmt.Println(displayPath(root, file))
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package duplicate_file_handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Snapshot is a full scan result with the hash of every file, kept to compare scans over time.
+type Snapshot struct {
+	Root    string         `json:"root"`
+	Created time.Time      `json:"created"`
+	Files   []SnapshotFile `json:"files"`
+}
+
+type SnapshotFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Hash string `json:"hash"`
+}
+
+type ChangedFile struct {
+	Path    string
+	OldHash string
+	NewHash string
+}
+
+type SnapshotDiff struct {
+	NewGroups      []DuplicateGroup
+	ResolvedGroups []DuplicateGroup
+	ChangedFiles   []ChangedFile
+	WastedBefore   int64
+	WastedAfter    int64
+}
+
+// Snapshot walks the tree and hashes every file, not only the ones sharing a size.
+func (s *Scanner) Snapshot() (Snapshot, error) {
+	snapshot := Snapshot{Created: time.Now()}
+	files, err := s.Walk()
+	if err != nil {
+		return snapshot, err
+	}
+	var all []File
+	for _, sizeFiles := range files {
+		all = append(all, sizeFiles...)
+	}
+	s.hashMembers(context.Background(), all)
+	for _, size := range s.Sizes(files) {
+		for _, file := range files[size] {
+			hash, err := s.hashFile(context.Background(), file.Path)
+			if err != nil {
+				return snapshot, err
+			}
+			snapshot.Files = append(snapshot.Files, SnapshotFile{file.Path, file.Size, hash})
+		}
+	}
+	sort.Slice(snapshot.Files, func(i, j int) bool { return snapshot.Files[i].Path < snapshot.Files[j].Path })
+	return snapshot, nil
+}
+
+// Groups returns the duplicate groups recorded in the snapshot ordered by size and hash.
+func (s Snapshot) Groups() []DuplicateGroup {
+	byHash := make(map[string]*DuplicateGroup)
+	for _, file := range s.Files {
+		group, ok := byHash[file.Hash]
+		if !ok {
+			group = &DuplicateGroup{Hash: file.Hash, Size: file.Size}
+			byHash[file.Hash] = group
+		}
+		group.Files = append(group.Files, File{Path: file.Path, Size: file.Size})
+	}
+	var groups []DuplicateGroup
+	for _, group := range byHash {
+		if group.Size > 0 && len(group.Files) > 1 {
+			groups = append(groups, *group)
+		}
+	}
+	sortGroups(groups)
+	return groups
+}
+
+func SaveSnapshot(path string, snapshot Snapshot) error {
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+func LoadSnapshot(path string) (Snapshot, error) {
+	var snapshot Snapshot
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(content, &snapshot)
+	return snapshot, err
+}
+
+// DiffSnapshots compares an older and a newer snapshot of the same tree.
+func DiffSnapshots(older, newer Snapshot) SnapshotDiff {
+	var diff SnapshotDiff
+	oldGroups := older.Groups()
+	newGroups := newer.Groups()
+	oldByHash := make(map[string]bool)
+	for _, group := range oldGroups {
+		oldByHash[group.Hash] = true
+		diff.WastedBefore += wastedSpace(group)
+	}
+	newByHash := make(map[string]bool)
+	for _, group := range newGroups {
+		newByHash[group.Hash] = true
+		diff.WastedAfter += wastedSpace(group)
+		if !oldByHash[group.Hash] {
+			diff.NewGroups = append(diff.NewGroups, group)
+		}
+	}
+	for _, group := range oldGroups {
+		if !newByHash[group.Hash] {
+			diff.ResolvedGroups = append(diff.ResolvedGroups, group)
+		}
+	}
+
+	oldHashes := make(map[string]string)
+	for _, file := range older.Files {
+		oldHashes[file.Path] = file.Hash
+	}
+	for _, file := range newer.Files {
+		if hash, ok := oldHashes[file.Path]; ok && hash != file.Hash {
+			diff.ChangedFiles = append(diff.ChangedFiles, ChangedFile{file.Path, hash, file.Hash})
+		}
+	}
+	return diff
+}
+
+func wastedSpace(group DuplicateGroup) int64 {
+	return group.Size * int64(len(group.Files)-1)
+}
+
+func sortGroups(groups []DuplicateGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size < groups[j].Size
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+}
+
+func saveSnapshot() {
+	root, fsys := readDirectory()
+	path := readFileName("Enter the snapshot file:")
+	snapshot, err := NewScanner(Options{FS: fsys}).Snapshot()
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	snapshot.Root = root
+	if err := SaveSnapshot(path, snapshot); err != nil {
+		exitProgram(1, err.Error())
+	}
+	fmt.Printf("Snapshot of %d files is saved to %s\n", len(snapshot.Files), path)
+}
+
+func compareSnapshots() {
+	var snapshots [2]Snapshot
+	for i, question := range []string{"Enter the older snapshot file:", "Enter the newer snapshot file:"} {
+		snapshot, err := LoadSnapshot(readFileName(question))
+		if err != nil {
+			exitProgram(1, err.Error())
+		}
+		snapshots[i] = snapshot
+	}
+	printSnapshotDiff(snapshots[0], snapshots[1], DiffSnapshots(snapshots[0], snapshots[1]))
+}
+
+func printSnapshotDiff(older, newer Snapshot, diff SnapshotDiff) {
+	fmt.Printf("Comparing %s with %s\n", older.Created.Format(time.DateTime), newer.Created.Format(time.DateTime))
+	fmt.Printf("New duplicate groups: %d\n", len(diff.NewGroups))
+	printGroups(diff.NewGroups, newer.Root)
+	fmt.Printf("Resolved duplicate groups: %d\n", len(diff.ResolvedGroups))
+	printGroups(diff.ResolvedGroups, older.Root)
+	fmt.Printf("Changed files: %d\n", len(diff.ChangedFiles))
+	for _, file := range diff.ChangedFiles {
+		fmt.Printf("%s: %s -> %s\n", displayPath(newer.Root, file.Path), file.OldHash, file.NewHash)
+	}
+	fmt.Printf("Wasted space: %d bytes -> %d bytes (%+d bytes)\n", diff.WastedBefore, diff.WastedAfter, diff.WastedAfter-diff.WastedBefore)
+}
+
+func printGroups(groups []DuplicateGroup, root string) {
+	for _, group := range groups {
+		fmt.Printf("Hash: %s, %d bytes\n", group.Hash, group.Size)
+		for _, file := range group.Files {
+			fmt.Println(displayPath(root, file.Path))
+		}
+	}
+}
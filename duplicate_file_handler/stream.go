@@ -0,0 +1,279 @@
+package duplicate_file_handler
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+)
+
+// maxMergeFanIn limits how many run files are open at once while merging.
+const maxMergeFanIn = 64
+
+// ScanFunc calls fn with every duplicate group in the same order as Scan. With
+// Options.MaxFilesInMemory set, walked files are spilled to sorted run files in
+// Options.SpillDir and merged back one size bucket at a time, so memory is bounded
+// by the largest bucket instead of the whole tree.
+func (s *Scanner) ScanFunc(fn func(DuplicateGroup) error) error {
+	return s.ScanFuncContext(context.Background(), fn)
+}
+
+// ScanFuncContext is ScanFunc that stops when ctx is done, after calling fn with the
+// groups completed so far, and returns the context error. Run files are removed either way.
+func (s *Scanner) ScanFuncContext(ctx context.Context, fn func(DuplicateGroup) error) error {
+	if s.options.MaxFilesInMemory <= 0 {
+		groups, scanErr := s.ScanContext(ctx)
+		for _, group := range groups {
+			if err := fn(group); err != nil {
+				return err
+			}
+		}
+		return scanErr
+	}
+
+	runs, total, err := s.spillRuns(ctx)
+	defer func() {
+		for _, run := range runs {
+			os.Remove(run)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	for len(runs) > maxMergeFanIn {
+		if runs, err = s.compactRuns(ctx, runs); err != nil {
+			return err
+		}
+	}
+
+	s.tracker.startHashing(total)
+	defer s.tracker.flush()
+	var bucket []File
+	flush := func() error {
+		s.hashMembers(ctx, bucket)
+		groups, err := s.groupByHash(ctx, bucket[0].Size, bucket)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			if err := fn(group); err != nil {
+				return err
+			}
+		}
+		bucket = nil
+		return nil
+	}
+	err = s.mergeRuns(ctx, runs, func(file File) error {
+		if len(bucket) > 0 && bucket[0].Size != file.Size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		bucket = append(bucket, file)
+		return nil
+	})
+	if err != nil || len(bucket) == 0 {
+		return err
+	}
+	return flush()
+}
+
+// spillRuns walks the tree and writes files to temporary runs of at most
+// MaxFilesInMemory records, each sorted in scan order. It also returns the bytes of
+// the files sharing their size with another file, which are the ones to hash.
+func (s *Scanner) spillRuns(ctx context.Context) ([]string, int64, error) {
+	var runs []string
+	var buffer []File
+	sizeCounts := make(map[int64]int)
+	flush := func() error {
+		if len(buffer) == 0 {
+			return nil
+		}
+		sort.Slice(buffer, func(i, j int) bool { return s.before(buffer[i], buffer[j]) })
+		run, err := s.writeRun(func(write func(File) error) error {
+			for _, file := range buffer {
+				if err := write(file); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if run != "" {
+			runs = append(runs, run)
+		}
+		buffer = buffer[:0]
+		return err
+	}
+
+	err := s.walkFunc(ctx, func(file File) error {
+		sizeCounts[file.Size]++
+		buffer = append(buffer, file)
+		if len(buffer) >= s.options.MaxFilesInMemory {
+			return flush()
+		}
+		return nil
+	})
+	s.tracker.flush()
+	if err != nil {
+		return runs, 0, err
+	}
+	var total int64
+	for size, count := range sizeCounts {
+		if count > 1 {
+			total += size * int64(count)
+		}
+	}
+	return runs, total, flush()
+}
+
+// compactRuns merges runs in batches of maxMergeFanIn into fewer, longer runs.
+func (s *Scanner) compactRuns(ctx context.Context, runs []string) ([]string, error) {
+	var result []string
+	for start := 0; start < len(runs); start += maxMergeFanIn {
+		batch := runs[start:min(start+maxMergeFanIn, len(runs))]
+		run, err := s.writeRun(func(write func(File) error) error {
+			return s.mergeRuns(ctx, batch, write)
+		})
+		if run != "" {
+			result = append(result, run)
+		}
+		if err != nil {
+			return append(result, runs[start:]...), err
+		}
+		for _, name := range batch {
+			os.Remove(name)
+		}
+	}
+	return result, nil
+}
+
+func (s *Scanner) writeRun(fill func(write func(File) error) error) (string, error) {
+	run, err := os.CreateTemp(s.options.SpillDir, "dup-run-*")
+	if err != nil {
+		return "", err
+	}
+	defer run.Close()
+	w := bufio.NewWriter(run)
+	encoder := gob.NewEncoder(w)
+	if err := fill(func(file File) error { return encoder.Encode(file) }); err != nil {
+		return run.Name(), err
+	}
+	if err := w.Flush(); err != nil {
+		return run.Name(), err
+	}
+	return run.Name(), run.Close()
+}
+
+// mergeRuns merges the sorted runs and calls fn with every file in scan order.
+func (s *Scanner) mergeRuns(ctx context.Context, runs []string, fn func(File) error) error {
+	h := &runHeap{before: s.before}
+	for _, run := range runs {
+		f, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		cursor := &runCursor{decoder: gob.NewDecoder(bufio.NewReader(f))}
+		ok, err := cursor.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.cursors = append(h.cursors, cursor)
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cursor := h.cursors[0]
+		if err := fn(cursor.file); err != nil {
+			return err
+		}
+		ok, err := cursor.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// before orders files by size in the configured direction and then by path.
+func (s *Scanner) before(a, b File) bool {
+	if a.Size != b.Size {
+		if s.options.Descending {
+			return a.Size > b.Size
+		}
+		return a.Size < b.Size
+	}
+	return a.Path < b.Path
+}
+
+type runCursor struct {
+	decoder *gob.Decoder
+	file    File
+}
+
+func (c *runCursor) next() (bool, error) {
+	c.file = File{}
+	err := c.decoder.Decode(&c.file)
+	if err == io.EOF {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+type runHeap struct {
+	cursors []*runCursor
+	before  func(a, b File) bool
+}
+
+func (h *runHeap) Len() int           { return len(h.cursors) }
+func (h *runHeap) Less(i, j int) bool { return h.before(h.cursors[i].file, h.cursors[j].file) }
+func (h *runHeap) Swap(i, j int)      { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+func (h *runHeap) Push(x any)         { h.cursors = append(h.cursors, x.(*runCursor)) }
+func (h *runHeap) Pop() any {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
+
+func findDuplicatesInLargeTree() {
+	request := createCollectingRequest()
+	options := request.options()
+	options.MaxFilesInMemory = 100000
+	options.Progress = printProgress
+
+	// Cancelling returns through ScanFuncContext, which removes the run files.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	var wasted int64
+	err := NewScanner(options).ScanFuncContext(ctx, func(group DuplicateGroup) error {
+		fmt.Fprintln(os.Stderr)
+		wasted += wastedSpace(group)
+		printGroups([]DuplicateGroup{group}, request.folder)
+		return nil
+	})
+	stop()
+	fmt.Fprintln(os.Stderr)
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf("Scan is cancelled, wasted space so far: %d bytes\n", wasted)
+		exitProgram(1)
+	}
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	fmt.Printf("Total wasted space: %d bytes\n", wasted)
+}
@@ -0,0 +1,84 @@
+package duplicate_file_handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestScanFuncMatchesScan(t *testing.T) {
+	// One file per run gives more runs than maxMergeFanIn, so they are compacted first.
+	fsys := make(fstest.MapFS)
+	for i := 0; i < 3*maxMergeFanIn; i++ {
+		content := strings.Repeat("x", i%7+1) + fmt.Sprint(i%5)
+		fsys[fmt.Sprintf("dir%d/file%03d.txt", i%4, i)] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	for _, descending := range []bool{false, true} {
+		t.Run(fmt.Sprintf("descending=%t", descending), func(t *testing.T) {
+			want, err := NewScanner(Options{FS: fsys, Descending: descending}).Scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(want) == 0 {
+				t.Fatal("Scan found no duplicates")
+			}
+
+			spillDir := t.TempDir()
+			options := Options{FS: fsys, Descending: descending, MaxFilesInMemory: 1, SpillDir: spillDir}
+			var got []DuplicateGroup
+			err = NewScanner(options).ScanFunc(func(group DuplicateGroup) error {
+				got = append(got, group)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("ScanFunc returned %d groups, Scan %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].Hash != want[i].Hash || got[i].Size != want[i].Size ||
+					!reflect.DeepEqual(groupPaths(got[i:i+1]), groupPaths(want[i:i+1])) {
+					t.Errorf("group %d: got %v, want %v", i, got[i], want[i])
+				}
+			}
+
+			left, err := os.ReadDir(spillDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != 0 {
+				t.Errorf("%d run files left in the spill directory", len(left))
+			}
+		})
+	}
+}
+
+func TestScanFuncContextCancelled(t *testing.T) {
+	fsys := make(fstest.MapFS)
+	for i := 0; i < 10; i++ {
+		fsys[fmt.Sprintf("file%d.txt", i)] = &fstest.MapFile{Data: []byte("same")}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	spillDir := t.TempDir()
+	options := Options{FS: fsys, MaxFilesInMemory: 2, SpillDir: spillDir}
+	err := NewScanner(options).ScanFuncContext(ctx, func(DuplicateGroup) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ScanFuncContext error = %v, want context.Canceled", err)
+	}
+	left, err := os.ReadDir(spillDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Errorf("%d run files left in the spill directory", len(left))
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package duplicate_file_handler
+
+import "errors"
+
+type terminalState struct{}
+
+var errNoRawTerminal = errors.New("terminal UI requires Linux")
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errNoRawTerminal
+}
+
+func restoreTerminal(fd int, state *terminalState) error {
+	return errNoRawTerminal
+}
+
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errNoRawTerminal
+}
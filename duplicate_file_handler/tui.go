@@ -0,0 +1,270 @@
+package duplicate_file_handler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	keyUp    = "\x1b[A"
+	keyDown  = "\x1b[B"
+	keyRight = "\x1b[C"
+	keyLeft  = "\x1b[D"
+)
+
+// review is the state of the full-screen review of duplicate groups.
+type review struct {
+	groups     []DuplicateGroup
+	marked     [][]bool
+	group      int
+	cursor     int
+	offset     int
+	rule       int
+	root       string
+	status     string
+	confirming bool
+	width      int
+	height     int
+	out        *bufio.Writer
+}
+
+type reviewResult int
+
+const (
+	reviewContinue reviewResult = iota
+	reviewQuit
+	reviewCommit
+)
+
+// runReview lets the user mark files for deletion group by group in raw terminal mode
+// and returns the resulting plan, which is empty if the user quits.
+func runReview(groups []DuplicateGroup, root string) (Plan, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := makeRaw(fd)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer restoreTerminal(fd, state)
+
+	r := &review{groups: groups, root: root, out: bufio.NewWriter(os.Stdout)}
+	for _, group := range groups {
+		r.marked = append(r.marked, make([]bool, len(group.Files)))
+	}
+	// Switch to the alternate screen and hide the cursor while reviewing.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	keys := make([]byte, 16)
+	for {
+		r.width, r.height, err = terminalSize(fd)
+		if err != nil || r.height == 0 {
+			r.width, r.height = 80, 24
+		}
+		r.draw()
+		n, err := os.Stdin.Read(keys)
+		if err != nil {
+			return Plan{}, err
+		}
+		switch r.handle(string(keys[:n])) {
+		case reviewQuit:
+			return Plan{}, nil
+		case reviewCommit:
+			return r.plan(), nil
+		}
+	}
+}
+
+func (r *review) handle(key string) reviewResult {
+	r.status = ""
+	if r.confirming {
+		r.confirming = false
+		if key == "y" || key == "Y" {
+			return reviewCommit
+		}
+		r.status = "Commit cancelled"
+		return reviewContinue
+	}
+	files := r.groups[r.group].Files
+	switch key {
+	case "q", "\x03":
+		return reviewQuit
+	case keyUp, "k":
+		if r.cursor > 0 {
+			r.cursor--
+		}
+	case keyDown, "j":
+		if r.cursor < len(files)-1 {
+			r.cursor++
+		}
+	case keyLeft, "p":
+		if r.group > 0 {
+			r.group--
+			r.cursor, r.offset = 0, 0
+		}
+	case keyRight, "n":
+		if r.group < len(r.groups)-1 {
+			r.group++
+			r.cursor, r.offset = 0, 0
+		}
+	case " ":
+		if isArchiveMember(files[r.cursor].Path) {
+			r.status = "Archive members are read-only"
+			break
+		}
+		r.marked[r.group][r.cursor] = !r.marked[r.group][r.cursor]
+	case "u":
+		r.marked[r.group] = make([]bool, len(files))
+	case "r":
+		// Like Policy.Plan, the rule only chooses among regular files, as an archive
+		// member cannot stand in for the kept copy.
+		var loose []File
+		var indexes []int
+		for i, file := range files {
+			if !isArchiveMember(file.Path) {
+				loose = append(loose, file)
+				indexes = append(indexes, i)
+			}
+		}
+		if len(loose) < 2 {
+			r.status = "A rule needs two regular files, the rest are archive members"
+			break
+		}
+		rule := KeepRules[r.rule]
+		r.rule = (r.rule + 1) % len(KeepRules)
+		group := r.groups[r.group]
+		kept, _ := rule.Keep(DuplicateGroup{group.Hash, group.Size, loose})
+		r.marked[r.group] = make([]bool, len(files))
+		for k, i := range indexes {
+			r.marked[r.group][i] = k != kept
+		}
+		r.status = fmt.Sprintf("Applied rule: keep %s", rule)
+	case "c":
+		count, bytes := r.totals()
+		if count == 0 {
+			r.status = "Nothing is marked"
+			break
+		}
+		r.confirming = true
+		r.status = fmt.Sprintf("Delete %d files, %d bytes? (y/n)", count, bytes)
+	}
+	return reviewContinue
+}
+
+func (r *review) totals() (int, int64) {
+	count, bytes := 0, int64(0)
+	for g, marks := range r.marked {
+		for _, marked := range marks {
+			if marked {
+				count++
+				bytes += r.groups[g].Size
+			}
+		}
+	}
+	return count, bytes
+}
+
+func (r *review) plan() Plan {
+	var plan Plan
+	for g, marks := range r.marked {
+		for i, marked := range marks {
+			if marked {
+				file := r.groups[g].Files[i]
+				plan.Actions = append(plan.Actions, Action{Kind: Delete, Path: file.Path, Size: file.Size})
+			}
+		}
+	}
+	return plan
+}
+
+func (r *review) draw() {
+	group := r.groups[r.group]
+	marks := r.marked[r.group]
+	r.out.WriteString("\x1b[H\x1b[2J")
+	r.line("Group %d of %d: %d copies of %d bytes, hash %s", r.group+1, len(r.groups), len(group.Files), group.Size, group.Hash)
+	r.line("Next rule: keep %s", KeepRules[r.rule])
+	r.line("")
+
+	// Keep the cursor inside the visible part of the file list.
+	rows := max(r.height-11, 1)
+	if r.cursor < r.offset {
+		r.offset = r.cursor
+	}
+	if r.cursor >= r.offset+rows {
+		r.offset = r.cursor - rows + 1
+	}
+	// Archive members are never marked, so the warning is about the regular files.
+	loose, kept := 0, 0
+	for i, file := range group.Files {
+		if !isArchiveMember(file.Path) {
+			loose++
+			if !marks[i] {
+				kept++
+			}
+		}
+		if i < r.offset || i >= r.offset+rows {
+			continue
+		}
+		pointer, mark := " ", "keep"
+		if i == r.cursor {
+			pointer = ">"
+		}
+		if marks[i] {
+			mark = "DEL "
+		} else if isArchiveMember(file.Path) {
+			mark = " ro "
+		}
+		r.line("%s [%s] %s", pointer, mark, displayPath(r.root, file.Path))
+	}
+	r.line("")
+
+	file := group.Files[r.cursor]
+	r.line("Path:     %s", displayPath(r.root, file.Path))
+	r.line("Size:     %d bytes", file.Size)
+	r.line("Modified: %s", file.ModTime.Format(time.DateTime))
+	count, bytes := r.totals()
+	r.line("Marked:   %d files, %d bytes in all groups", count, bytes)
+	if loose > 0 && kept == 0 && r.status == "" {
+		r.status = "Warning: every copy in this group is marked"
+	}
+	r.line("%s", r.status)
+	r.line("arrows, j/k, n/p: move  space: toggle  r: apply rule  u: unmark  c: commit  q: quit")
+	r.out.Flush()
+}
+
+// line writes one row, cut to the terminal width. Raw mode needs explicit carriage returns.
+func (r *review) line(format string, args ...any) {
+	text := []rune(fmt.Sprintf(format, args...))
+	if r.width > 0 && len(text) > r.width {
+		text = text[:r.width]
+	}
+	r.out.WriteString(strings.TrimRight(string(text), " ") + "\r\n")
+}
+
+func reviewDuplicates() {
+	request := createCollectingRequest()
+	options := request.options()
+	options.Progress = printProgress
+	groups, err := NewScanner(options).Scan()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	if len(groups) == 0 {
+		exitProgram(0, "No duplicates found")
+	}
+	plan, err := runReview(groups, request.folder)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	if len(plan.Actions) == 0 {
+		exitProgram(0, "Nothing is deleted")
+	}
+	result, err := Apply(request.fsys, plan)
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	fmt.Printf("Total freed up space: %d bytes\n", result.FreedBytes)
+}
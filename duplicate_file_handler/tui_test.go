@@ -0,0 +1,31 @@
+package duplicate_file_handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReviewRuleKeepsRegularFile(t *testing.T) {
+	groups := []DuplicateGroup{
+		{Hash: "ab", Size: 3, Files: []File{{Path: "a/bundle.zip!/x", Size: 3}, {Path: "b/x", Size: 3}, {Path: "c/x", Size: 3}}},
+		{Hash: "cd", Size: 3, Files: []File{{Path: "a/bundle.zip!/y", Size: 3}, {Path: "b/y", Size: 3}}},
+	}
+	r := &review{groups: groups}
+	for _, group := range groups {
+		r.marked = append(r.marked, make([]bool, len(group.Files)))
+	}
+
+	r.handle("r")
+	if want := []bool{false, false, true}; !reflect.DeepEqual(r.marked[0], want) {
+		t.Errorf("keep %s marked %v, want %v", KeepRules[0], r.marked[0], want)
+	}
+
+	r.handle("n")
+	r.handle("r")
+	if want := []bool{false, false}; !reflect.DeepEqual(r.marked[1], want) {
+		t.Errorf("with one regular file marked %v, want %v", r.marked[1], want)
+	}
+	if r.status == "" {
+		t.Error("no status about the rule not applying")
+	}
+}
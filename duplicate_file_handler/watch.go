@@ -0,0 +1,249 @@
+package duplicate_file_handler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchEvent reports a file that appeared with the same content as already indexed files.
+type WatchEvent struct {
+	Time       time.Time `json:"time"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Hash       string    `json:"hash"`
+	Duplicates []string  `json:"duplicates"`
+}
+
+// watchIndex is the persistent path to hash index of the watched roots. Files whose
+// size and modification time did not change since the last run are not hashed again.
+type watchIndex struct {
+	path   string
+	Files  map[string]indexEntry `json:"files"`
+	byHash map[string]map[string]bool
+	// changed is set when Files differs from the saved index.
+	changed bool
+}
+
+type indexEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+}
+
+func loadWatchIndex(path string) (*watchIndex, bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false, err
+	}
+	index := &watchIndex{path: path, Files: make(map[string]indexEntry), byHash: make(map[string]map[string]bool)}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		index.changed = true
+		return index, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(content, index); err != nil {
+		return nil, false, err
+	}
+	for file, entry := range index.Files {
+		index.link(file, entry.Hash)
+	}
+	return index, true, nil
+}
+
+// save writes the index if it changed since it was loaded or last saved.
+func (x *watchIndex) save() error {
+	if !x.changed {
+		return nil
+	}
+	content, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, x.path); err != nil {
+		return err
+	}
+	x.changed = false
+	return nil
+}
+
+// ignored reports whether the path is the index itself or its temporary file, whose
+// writes must not be indexed when the index lives inside a watched root.
+func (x *watchIndex) ignored(path string) bool {
+	return path == x.path || path == x.path+".tmp"
+}
+
+// sync brings the index in line with the roots. With report set, files that were not
+// indexed before are checked for duplicates like files written while watching.
+func (x *watchIndex) sync(roots []string, report bool, emit func(WatchEvent)) error {
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() || x.ignored(path) {
+				return nil
+			}
+			seen[path] = true
+			_, known := x.Files[path]
+			event, err := x.update(path)
+			if vanishedOrUnreadable(err) {
+				return nil
+			}
+			if err == nil && report && !known && event != nil {
+				emit(*event)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for path := range x.Files {
+		if !seen[path] {
+			x.remove(path)
+		}
+	}
+	return x.save()
+}
+
+// update indexes the file and returns an event if its content is already indexed under another path.
+func (x *watchIndex) update(path string) (*WatchEvent, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		x.remove(path)
+		return nil, nil
+	}
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, err
+	}
+	entry, ok := x.Files[path]
+	if ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return nil, nil
+	}
+	hash, err := hashOSFile(path)
+	if err != nil {
+		return nil, err
+	}
+	x.remove(path)
+	x.Files[path] = indexEntry{info.Size(), info.ModTime(), hash}
+	x.changed = true
+
+	var duplicates []string
+	for other := range x.byHash[hash] {
+		duplicates = append(duplicates, other)
+	}
+	x.link(path, hash)
+	if len(duplicates) == 0 || info.Size() == 0 {
+		return nil, nil
+	}
+	sort.Strings(duplicates)
+	return &WatchEvent{time.Now(), path, info.Size(), hash, duplicates}, nil
+}
+
+// removeTree drops the path and everything indexed below it.
+func (x *watchIndex) removeTree(path string) {
+	prefix := path + string(filepath.Separator)
+	for file := range x.Files {
+		if file == path || strings.HasPrefix(file, prefix) {
+			x.remove(file)
+		}
+	}
+}
+
+func (x *watchIndex) remove(path string) {
+	entry, ok := x.Files[path]
+	if !ok {
+		return
+	}
+	delete(x.Files, path)
+	x.changed = true
+	delete(x.byHash[entry.Hash], path)
+	if len(x.byHash[entry.Hash]) == 0 {
+		delete(x.byHash, entry.Hash)
+	}
+}
+
+func (x *watchIndex) link(path string, hash string) {
+	if x.byHash[hash] == nil {
+		x.byHash[hash] = make(map[string]bool)
+	}
+	x.byHash[hash][path] = true
+}
+
+// vanishedOrUnreadable reports errors of files removed before they were hashed or that
+// cannot be read, which are left out of the index rather than stopping the watch.
+func vanishedOrUnreadable(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission)
+}
+
+func hashOSFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func watchDuplicates() {
+	fmt.Println("Enter the directories to watch:")
+	line, err := readLine()
+	if err != nil {
+		exitProgram(1, err.Error())
+	}
+	roots := strings.Fields(line)
+	if len(roots) == 0 {
+		exitProgram(1, "Directory is not specified")
+	}
+	for i, root := range roots {
+		if roots[i], err = filepath.Abs(root); err != nil {
+			exitProgram(1, err.Error())
+		}
+	}
+	indexPath := readFileName("Enter the index file:")
+	asJSON := yesOrNoQuestion("Print events as JSON?")
+
+	emit := func(event WatchEvent) {
+		log.Printf("duplicate: %s (%d bytes) has the same content as %s", event.Path, event.Size, strings.Join(event.Duplicates, ", "))
+	}
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		emit = func(event WatchEvent) {
+			if err := encoder.Encode(event); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Println("Watching, press Ctrl+C to stop")
+	err = Watch(ctx, roots, indexPath, emit)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		exitProgram(1, err.Error())
+	}
+}
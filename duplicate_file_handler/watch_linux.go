@@ -0,0 +1,176 @@
+//go:build linux
+
+package duplicate_file_handler
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_DELETE_SELF
+
+// Watch indexes the roots, then uses inotify to hash every file written or moved into them
+// and calls emit when its content duplicates an indexed file. The index is stored at
+// indexPath after every batch of changes, so a restart only hashes modified files.
+// Watch runs until ctx is done.
+func Watch(ctx context.Context, roots []string, indexPath string, emit func(WatchEvent)) error {
+	index, existed, err := loadWatchIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	// Event paths are compared with the absolute index path.
+	absRoots := make([]string, len(roots))
+	for i, root := range roots {
+		if absRoots[i], err = filepath.Abs(root); err != nil {
+			return err
+		}
+	}
+	roots = absRoots
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// A non-blocking descriptor wrapped in os.File is served by the runtime poller,
+	// so closing the file interrupts a pending Read.
+	inotify := os.NewFile(uintptr(fd), "inotify")
+	defer inotify.Close()
+
+	w := &inotifyWatcher{fd: fd, dirs: make(map[int32]string)}
+	for _, root := range roots {
+		if err := w.addTree(root); err != nil {
+			return err
+		}
+	}
+	if err := index.sync(roots, existed, emit); err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		inotify.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := inotify.Read(buf)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			dir, ok := w.dirs[event.Wd]
+			if !ok {
+				continue
+			}
+			if event.Mask&(syscall.IN_DELETE_SELF|syscall.IN_IGNORED) != 0 {
+				delete(w.dirs, event.Wd)
+				continue
+			}
+			path := filepath.Join(dir, cString(nameBytes))
+			if err := w.handle(index, path, event.Mask, emit); err != nil {
+				return err
+			}
+		}
+		if err := index.save(); err != nil {
+			return err
+		}
+	}
+}
+
+type inotifyWatcher struct {
+	fd   int
+	dirs map[int32]string
+}
+
+func (w *inotifyWatcher) handle(index *watchIndex, path string, mask uint32, emit func(WatchEvent)) error {
+	if index.ignored(path) {
+		return nil
+	}
+	switch {
+	case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		index.removeTree(path)
+		if mask&(syscall.IN_ISDIR|syscall.IN_MOVED_FROM) == syscall.IN_ISDIR|syscall.IN_MOVED_FROM {
+			// The watches follow the moved directory, drop them until it shows up again
+			// with IN_MOVED_TO, which watches it under the new path.
+			w.removeTree(path)
+		}
+	case mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		// Files may land in a new directory before its watch exists, so index it right away.
+		if err := w.addTree(path); err != nil {
+			return err
+		}
+		return filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			return w.updateAndEmit(index, file, emit)
+		})
+	case mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0:
+		return w.updateAndEmit(index, path, emit)
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) updateAndEmit(index *watchIndex, path string, emit func(WatchEvent)) error {
+	event, err := index.update(path)
+	if vanishedOrUnreadable(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if event != nil {
+		emit(*event)
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+// removeTree stops watching the directory and the directories below it.
+func (w *inotifyWatcher) removeTree(root string) {
+	prefix := root + string(filepath.Separator)
+	for wd, dir := range w.dirs {
+		if dir == root || strings.HasPrefix(dir, prefix) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package duplicate_file_handler
+
+import (
+	"context"
+	"errors"
+)
+
+// Watch is only available on Linux, where it is built on inotify.
+func Watch(ctx context.Context, roots []string, indexPath string, emit func(WatchEvent)) error {
+	return errors.New("watch mode requires Linux inotify")
+}
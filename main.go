@@ -6,6 +6,7 @@ import (
 	"GoDeveloperPath/smart_calculator"
 	"GoDeveloperPath/vcs"
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
@@ -19,6 +20,16 @@ var projects = []string{
 }
 
 func main() {
+	policy := flag.String("policy", "", "run the Duplicate File Handler with a JSON policy file and exit")
+	flag.Parse()
+	if *policy != "" {
+		if err := duplicate_file_handler.RunPolicy(*policy, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	projectNum, err := chooseProject()
 	if err != nil {
 		fmt.Println(err)
@@ -0,0 +1,136 @@
+package smart_calculator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxCallDepth stops recursive user functions, which have no way to end the recursion.
+const maxCallDepth = 1000
+
+// function is a user-defined function like f(x, y) = x^2 + y. Its body may use global
+// variables and other functions, which are looked up when it is called.
+type function struct {
+	params []string
+	body   node
+	source string
+}
+
+var (
+	functions  = make(map[string]function)
+	definition = regexp.MustCompile(`^([a-zA-Z]+)\s*\(([^()]*)\)\s*=`)
+	identifier = regexp.MustCompile(`^[a-zA-Z]+$`)
+)
+
+func isDefinition(line string) bool {
+	return definition.MatchString(line)
+}
+
+// define parses the definition on the line and stores the function, replacing any
+// function with the same name.
+func define(line string) error {
+	match := definition.FindStringSubmatchIndex(line)
+	name := line[match[2]:match[3]]
+	if _, ok := builtins[name]; ok {
+		return errorAt(0, "%s is a built-in function and cannot be redefined", name)
+	}
+	var params []string
+	if list := strings.TrimSpace(line[match[4]:match[5]]); list != "" {
+		for _, param := range strings.Split(list, ",") {
+			param = strings.TrimSpace(param)
+			if !identifier.MatchString(param) {
+				return errorAt(len([]rune(line[:match[4]])), "Invalid parameter %q", param)
+			}
+			for _, seen := range params {
+				if seen == param {
+					return errorAt(len([]rune(line[:match[4]])), "Parameter %q is repeated", param)
+				}
+			}
+			params = append(params, param)
+		}
+	}
+
+	source := line[match[1]:]
+	body, err := parse(source)
+	if err != nil {
+		// Point at the column of the whole line rather than of the body.
+		var calcErr *calcError
+		if errors.As(err, &calcErr) {
+			calcErr.pos += len([]rune(line[:match[1]]))
+		}
+		return err
+	}
+	functions[name] = function{params, body, strings.TrimSpace(source)}
+	return nil
+}
+
+// callFunction evaluates the body of the user function with the arguments bound to its
+// parameters. Errors inside the body point at the call, naming the function they come from.
+func (s *scope) callFunction(n callNode, f function, args []*big.Rat) (*big.Rat, error) {
+	if len(args) != len(f.params) {
+		return nil, errorAt(n.at, "%s takes %s, got %d", n.name, arguments(len(f.params)), len(args))
+	}
+	if s.depth >= maxCallDepth {
+		return nil, &calcError{n.at, fmt.Sprintf("Recursion is deeper than %d calls", maxCallDepth), n.name}
+	}
+	inner := &scope{make(map[string]*big.Rat), s.depth + 1}
+	for i, param := range f.params {
+		inner.args[param] = args[i]
+	}
+	result, err := inner.evaluate(f.body)
+	var calcErr *calcError
+	if errors.As(err, &calcErr) {
+		function := calcErr.function
+		if function == "" {
+			function = n.name
+		}
+		return nil, &calcError{n.at, calcErr.msg, function}
+	}
+	if err == nil && mode == intMode {
+		// The body may hold decimals from a definition made in another mode.
+		return truncate(new(big.Rat).Set(result)), nil
+	}
+	return result, err
+}
+
+func (f function) String() string {
+	return fmt.Sprintf("(%s) = %s", strings.Join(f.params, ", "), f.source)
+}
+
+func listFunctions() {
+	if len(functions) == 0 {
+		fmt.Println("No functions are defined")
+		return
+	}
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name + functions[name].String())
+	}
+}
+
+// deleteName removes the function and the variable with the name given after /delete.
+func deleteName(str string) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		fmt.Println("Use /delete name")
+		return
+	}
+	name := fields[1]
+	_, isFunction := functions[name]
+	_, isVariable := memory[name]
+	if !isFunction && !isVariable {
+		fmt.Printf("%s is not defined\n", name)
+		return
+	}
+	delete(functions, name)
+	delete(memory, name)
+	delete(approximations, name)
+}
@@ -0,0 +1,66 @@
+package smart_calculator
+
+import "testing"
+
+func TestUserFunctionsFollowTheMode(t *testing.T) {
+	resetState(t)
+	if err := define("h(x) = x * 0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := define("g(x) = x / 2 + 1/3"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mode, line, want string
+	}{
+		{rationalMode, "h(3)", "3/2"},
+		{rationalMode, "g(3)", "11/6"},
+		{intMode, "h(3)", "1"},
+		{intMode, "h(-3)", "-1"},
+		{intMode, "g(3)", "1"},
+		{intMode, "7 / 2", "3"},
+		{intMode, "-7 / 2", "-3"},
+	}
+	for _, test := range tests {
+		mode = test.mode
+		if got := calculate(t, test.line); got != test.want {
+			t.Errorf("%s in %s mode = %s, want %s", test.line, test.mode, got, test.want)
+		}
+	}
+}
+
+func TestIntDivisionUsesWholeValues(t *testing.T) {
+	resetState(t)
+	// Only the bodies of functions defined in another mode hold fractions in int mode.
+	if err := define("k(x) = x * 0.5 / 0.25"); err != nil {
+		t.Fatal(err)
+	}
+	mode = intMode
+	if got := calculate(t, "k(3)"); got != "6" {
+		t.Errorf("k(3) = %s, want 6", got)
+	}
+}
+
+func TestArityErrors(t *testing.T) {
+	resetState(t)
+	if err := define("f(x) = x"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line, want string
+	}{
+		{"f(1, 2)", "f takes 1 argument, got 2"},
+		{"sqrt(1, 2)", "sqrt takes 1 argument, got 2"},
+		{"log()", "log takes 1 to 2 arguments, got 0"},
+		{"gcd(4)", "gcd takes at least 2 arguments, got 1"},
+	}
+	for _, test := range tests {
+		stmt, err := parseStatement(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := evaluate(stmt); err == nil || err.Error() != test.want {
+			t.Errorf("%s: error %v, want %q", test.line, err, test.want)
+		}
+	}
+}
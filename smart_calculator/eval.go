@@ -0,0 +1,180 @@
+package smart_calculator
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// maxPowerBits keeps integer powers from running out of memory. It bounds the bits of
+// the numerator and denominator of the result, 2 MB each.
+const maxPowerBits = 1 << 24
+
+// scope holds the arguments of the user function being evaluated, the top level of
+// a line has none.
+type scope struct {
+	args  map[string]*big.Rat
+	depth int
+}
+
+// evaluate computes the value of the syntax tree at the top level of a line.
+func evaluate(n node) (*big.Rat, error) {
+	return (&scope{}).evaluate(n)
+}
+
+// evaluate computes the value of the syntax tree. Errors point at the node they come from.
+func (s *scope) evaluate(n node) (*big.Rat, error) {
+	switch n := n.(type) {
+	case numberNode:
+		return n.value, nil
+	case variableNode:
+		if val, ok := s.args[n.name]; ok {
+			return val, nil
+		}
+		val, err := resolve(n.name)
+		if err != nil {
+			return nil, errorAt(n.at, "%s", err)
+		}
+		return val, nil
+	case unaryNode:
+		operand, err := s.evaluate(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "-" {
+			return new(big.Rat).Neg(operand), nil
+		}
+		return operand, nil
+	case callNode:
+		args := make([]*big.Rat, len(n.args))
+		for i, arg := range n.args {
+			val, err := s.evaluate(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = val
+		}
+		if f, ok := functions[n.name]; ok {
+			return s.callFunction(n, f, args)
+		}
+		result, err := call(n.name, args)
+		if err != nil {
+			return nil, errorAt(n.at, "%s", err)
+		}
+		if mode == intMode {
+			return truncate(new(big.Rat).Set(result)), nil
+		}
+		return result, nil
+	case binaryNode:
+		left, err := s.evaluate(n.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := s.evaluate(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return applyOperator(n.op, left, right, n.at)
+	case assignNode:
+		value, err := s.evaluate(n.value)
+		if err != nil {
+			return nil, err
+		}
+		if n.op != "=" {
+			current, err := resolve(n.name)
+			if err != nil {
+				return nil, errorAt(n.nameAt, "%s", err)
+			}
+			value, err = applyOperator(strings.TrimSuffix(n.op, "="), current, value, n.at)
+			if err != nil {
+				return nil, err
+			}
+		}
+		memory[n.name] = value
+		if approximate {
+			approximations[n.name] = true
+		} else {
+			delete(approximations, n.name)
+		}
+		return value, nil
+	}
+	return nil, errorAt(n.pos(), invalidExpression)
+}
+
+// applyOperator applies the binary operator, reporting errors at the column at.
+func applyOperator(op string, left, right *big.Rat, at int) (*big.Rat, error) {
+	result := new(big.Rat)
+	switch op {
+	case "+":
+		return result.Add(left, right), nil
+	case "-":
+		return result.Sub(left, right), nil
+	case "*":
+		return result.Mul(left, right), nil
+	case "/":
+		if right.Sign() == 0 {
+			return nil, errorAt(at, divisionByZero)
+		}
+		if mode == intMode {
+			return truncate(result.Quo(left, right)), nil
+		}
+		return result.Quo(left, right), nil
+	case "%":
+		if right.Sign() == 0 {
+			return nil, errorAt(at, divisionByZero)
+		}
+		// The result has the sign of the dividend, like % on Go integers.
+		quotient := truncate(result.Quo(left, right))
+		return result.Sub(left, quotient.Mul(quotient, right)), nil
+	case "^":
+		result, err := power(left, right)
+		if err != nil {
+			return nil, errorAt(at, "%s", err)
+		}
+		if mode == intMode {
+			return truncate(result), nil
+		}
+		return result, nil
+	}
+	return nil, errorAt(at, invalidExpression)
+}
+
+// power raises base to an integer exponent exactly. Other exponents go through float64.
+func power(base, exponent *big.Rat) (*big.Rat, error) {
+	if !exponent.IsInt() {
+		b, _ := base.Float64()
+		e, _ := exponent.Float64()
+		return inexact(math.Pow(b, e))
+	}
+	e := new(big.Int).Abs(exponent.Num())
+	if base.Sign() == 0 && exponent.Sign() < 0 {
+		return nil, errors.New(divisionByZero)
+	}
+	// 0, 1 and -1 stay small whatever the exponent.
+	if base.Sign() == 0 || base.IsInt() && base.Num().CmpAbs(big.NewInt(1)) == 0 {
+		if exponent.Sign() == 0 {
+			return big.NewRat(1, 1), nil
+		}
+		if base.Sign() < 0 && e.Bit(0) == 0 {
+			return big.NewRat(1, 1), nil
+		}
+		return new(big.Rat).Set(base), nil
+	}
+	// The result takes at most bitlen(base) * e bits.
+	bits := big.NewInt(int64(max(base.Num().BitLen(), base.Denom().BitLen())))
+	if bits.Mul(bits, e).Cmp(big.NewInt(maxPowerBits)) > 0 {
+		return nil, errors.New("The result is too large")
+	}
+	num := new(big.Int).Exp(base.Num(), e, nil)
+	denom := new(big.Int).Exp(base.Denom(), e, nil)
+	if exponent.Sign() < 0 {
+		num, denom = denom, num
+	}
+	return new(big.Rat).SetFrac(num, denom), nil
+}
+
+// truncate rounds r toward zero in place, the way int mode rounds every result.
+func truncate(r *big.Rat) *big.Rat {
+	return r.SetInt(new(big.Int).Quo(r.Num(), r.Denom()))
+}
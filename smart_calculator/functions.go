@@ -0,0 +1,235 @@
+package smart_calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// maxFactorial keeps factorial from running for minutes.
+const maxFactorial = 10000
+
+type builtin struct {
+	// minArgs and maxArgs bound the number of arguments, maxArgs is -1 for any number.
+	minArgs, maxArgs int
+	fn               func(args []*big.Rat) (*big.Rat, error)
+}
+
+var builtins = map[string]builtin{
+	"sqrt":      {1, 1, sqrt},
+	"abs":       {1, 1, func(args []*big.Rat) (*big.Rat, error) { return new(big.Rat).Abs(args[0]), nil }},
+	"min":       {1, -1, func(args []*big.Rat) (*big.Rat, error) { return extreme(args, -1), nil }},
+	"max":       {1, -1, func(args []*big.Rat) (*big.Rat, error) { return extreme(args, 1), nil }},
+	"pow":       {2, 2, func(args []*big.Rat) (*big.Rat, error) { return power(args[0], args[1]) }},
+	"log":       {1, 2, logarithm},
+	"ln":        {1, 1, floatFunction(math.Log, 1, 0)},
+	"exp":       {1, 1, floatFunction(math.Exp, 0, 1)},
+	"sin":       {1, 1, floatFunction(math.Sin, 0, 0)},
+	"cos":       {1, 1, floatFunction(math.Cos, 0, 1)},
+	"tan":       {1, 1, floatFunction(math.Tan, 0, 0)},
+	"floor":     {1, 1, func(args []*big.Rat) (*big.Rat, error) { return floor(args[0]), nil }},
+	"ceil":      {1, 1, func(args []*big.Rat) (*big.Rat, error) { return ceil(args[0]), nil }},
+	"round":     {1, 1, round},
+	"gcd":       {2, -1, gcd},
+	"lcm":       {2, -1, lcm},
+	"factorial": {1, 1, factorial},
+}
+
+// call applies the built-in function to evaluated arguments after checking its arity.
+func call(name string, args []*big.Rat) (*big.Rat, error) {
+	f, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown function %q", name)
+	}
+	if len(args) < f.minArgs || (f.maxArgs >= 0 && len(args) > f.maxArgs) {
+		return nil, fmt.Errorf("%s takes %s, got %d", name, arity(f), len(args))
+	}
+	return f.fn(args)
+}
+
+func arity(f builtin) string {
+	switch {
+	case f.maxArgs < 0:
+		return "at least " + arguments(f.minArgs)
+	case f.minArgs == f.maxArgs:
+		return arguments(f.minArgs)
+	}
+	return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+}
+
+// arguments counts arguments in words, like "1 argument" or "2 arguments".
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+func builtinNames() string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// inexact turns a float64 result into a value and marks the line as approximate, so
+// rational mode shows it as a decimal rather than as a long fraction.
+func inexact(f float64) (*big.Rat, error) {
+	result := new(big.Rat).SetFloat64(f)
+	if result == nil {
+		return nil, errors.New("The result is not a real number")
+	}
+	approximate = true
+	return result, nil
+}
+
+// floatFunction computes fn with float64, except at x0 where the result is exactly y0.
+func floatFunction(fn func(float64) float64, x0, y0 int64) func(args []*big.Rat) (*big.Rat, error) {
+	return func(args []*big.Rat) (*big.Rat, error) {
+		if args[0].Cmp(big.NewRat(x0, 1)) == 0 {
+			return big.NewRat(y0, 1), nil
+		}
+		x, _ := args[0].Float64()
+		return inexact(fn(x))
+	}
+}
+
+// sqrt is exact for squares of rationals like 9/4.
+func sqrt(args []*big.Rat) (*big.Rat, error) {
+	x := args[0]
+	if x.Sign() < 0 {
+		return nil, errors.New("The result is not a real number")
+	}
+	num := new(big.Int).Sqrt(x.Num())
+	denom := new(big.Int).Sqrt(x.Denom())
+	root := new(big.Rat).SetFrac(num, denom)
+	if new(big.Rat).Mul(root, root).Cmp(x) == 0 {
+		return root, nil
+	}
+	f, _ := x.Float64()
+	return inexact(math.Sqrt(f))
+}
+
+// logarithm is the common logarithm of x, or the logarithm to the base given second.
+// It is exact when x is an integer power of the base, like log(100) or log(1/8, 2).
+func logarithm(args []*big.Rat) (*big.Rat, error) {
+	base := big.NewRat(10, 1)
+	if len(args) == 2 {
+		base = args[1]
+	}
+	if k, ok := exactLog(args[0], base); ok {
+		return k, nil
+	}
+	x, _ := args[0].Float64()
+	if len(args) == 1 {
+		return inexact(math.Log10(x))
+	}
+	b, _ := base.Float64()
+	return inexact(math.Log(x) / math.Log(b))
+}
+
+// exactLog returns the integer k with base^k = x, if there is one.
+func exactLog(x, base *big.Rat) (*big.Rat, bool) {
+	if x.Sign() <= 0 || base.Sign() <= 0 || base.Cmp(big.NewRat(1, 1)) == 0 {
+		return nil, false
+	}
+	fx, _ := x.Float64()
+	fb, _ := base.Float64()
+	k := math.Round(math.Log(fx) / math.Log(fb))
+	if math.IsNaN(k) || math.IsInf(k, 0) {
+		return nil, false
+	}
+	exponent := new(big.Rat).SetFloat64(k)
+	result, err := power(base, exponent)
+	if err != nil || result.Cmp(x) != 0 {
+		return nil, false
+	}
+	return exponent, true
+}
+
+// extreme returns the smallest argument for sign -1 and the largest for sign 1.
+func extreme(args []*big.Rat, sign int) *big.Rat {
+	result := args[0]
+	for _, arg := range args[1:] {
+		if arg.Cmp(result) == sign {
+			result = arg
+		}
+	}
+	return result
+}
+
+func floor(x *big.Rat) *big.Rat {
+	// The denominator of a big.Rat is positive, so Div rounds toward negative infinity.
+	return new(big.Rat).SetInt(new(big.Int).Div(x.Num(), x.Denom()))
+}
+
+func ceil(x *big.Rat) *big.Rat {
+	f := floor(new(big.Rat).Neg(x))
+	return f.Neg(f)
+}
+
+// round rounds halves away from zero.
+func round(args []*big.Rat) (*big.Rat, error) {
+	half := big.NewRat(1, 2)
+	x := args[0]
+	if x.Sign() < 0 {
+		return new(big.Rat).Neg(floor(new(big.Rat).Add(new(big.Rat).Neg(x), half))), nil
+	}
+	return floor(new(big.Rat).Add(x, half)), nil
+}
+
+func integers(name string, args []*big.Rat) ([]*big.Int, error) {
+	result := make([]*big.Int, len(args))
+	for i, arg := range args {
+		if !arg.IsInt() {
+			return nil, fmt.Errorf("%s takes integer arguments", name)
+		}
+		result[i] = arg.Num()
+	}
+	return result, nil
+}
+
+func gcd(args []*big.Rat) (*big.Rat, error) {
+	nums, err := integers("gcd", args)
+	if err != nil {
+		return nil, err
+	}
+	result := new(big.Int).Abs(nums[0])
+	for _, num := range nums[1:] {
+		result.GCD(nil, nil, result, new(big.Int).Abs(num))
+	}
+	return new(big.Rat).SetInt(result), nil
+}
+
+func lcm(args []*big.Rat) (*big.Rat, error) {
+	nums, err := integers("lcm", args)
+	if err != nil {
+		return nil, err
+	}
+	result := new(big.Int).Abs(nums[0])
+	for _, num := range nums[1:] {
+		if result.Sign() == 0 || num.Sign() == 0 {
+			result.SetInt64(0)
+			continue
+		}
+		divisor := new(big.Int).GCD(nil, nil, result, new(big.Int).Abs(num))
+		result.Mul(result, new(big.Int).Abs(num)).Quo(result, divisor)
+	}
+	return new(big.Rat).SetInt(result), nil
+}
+
+func factorial(args []*big.Rat) (*big.Rat, error) {
+	x := args[0]
+	if !x.IsInt() || x.Sign() < 0 {
+		return nil, errors.New("factorial takes a non-negative integer")
+	}
+	if x.Num().Cmp(big.NewInt(maxFactorial)) > 0 {
+		return nil, fmt.Errorf("factorial takes numbers up to %d", maxFactorial)
+	}
+	return new(big.Rat).SetInt(new(big.Int).MulRange(1, x.Num().Int64())), nil
+}
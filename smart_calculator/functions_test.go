@@ -0,0 +1,98 @@
+package smart_calculator
+
+import (
+	"math/big"
+	"testing"
+)
+
+// calculate evaluates the line like calculateLine and returns what it would print.
+func calculate(t *testing.T, line string) string {
+	t.Helper()
+	approximate = false
+	expr, err := parseStatement(line)
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+	result, err := evaluate(expr)
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+	return formatNumber(result)
+}
+
+// resetState gives the test a fresh calculator in the default mode.
+func resetState(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		memory = make(map[string]*big.Rat)
+		approximations = make(map[string]bool)
+		functions = make(map[string]function)
+		mode = rationalMode
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestBuiltinsInDefaultMode(t *testing.T) {
+	resetState(t)
+	tests := []struct {
+		name, line, want string
+	}{
+		{"sqrt", "sqrt(9/4)", "3/2"},
+		{"sqrt", "sqrt(2)", "1.4142135623731 (approximate)"},
+		{"abs", "abs(-3/4)", "3/4"},
+		{"min", "min(3, 1/2, 2)", "1/2"},
+		{"max", "max(3, 1/2, 2)", "3"},
+		{"pow", "pow(2/3, 3)", "8/27"},
+		{"pow", "pow(4, 0.5)", "2 (approximate)"},
+		{"log", "log(100)", "2"},
+		{"log", "log(0.001)", "-3"},
+		{"log", "log(1/8, 2)", "-3"},
+		{"log", "log(2)", "0.301029995663981 (approximate)"},
+		{"ln", "ln(1)", "0"},
+		{"ln", "ln(2)", "0.693147180559945 (approximate)"},
+		{"exp", "exp(0)", "1"},
+		{"exp", "exp(1)", "2.71828182845905 (approximate)"},
+		{"sin", "sin(0)", "0"},
+		{"sin", "sin(1)", "0.841470984807897 (approximate)"},
+		{"cos", "cos(0)", "1"},
+		{"cos", "cos(1)", "0.54030230586814 (approximate)"},
+		{"tan", "tan(0)", "0"},
+		{"tan", "tan(1)", "1.5574077246549 (approximate)"},
+		{"floor", "floor(-7/2)", "-4"},
+		{"ceil", "ceil(7/2)", "4"},
+		{"round", "round(5/2)", "3"},
+		{"round", "round(-5/2)", "-3"},
+		{"gcd", "gcd(12, 18, 8)", "2"},
+		{"lcm", "lcm(4, 6)", "12"},
+		{"factorial", "factorial(5)", "120"},
+	}
+	tested := make(map[string]bool)
+	for _, test := range tests {
+		tested[test.name] = true
+		if got := calculate(t, test.line); got != test.want {
+			t.Errorf("%s = %s, want %s", test.line, got, test.want)
+		}
+	}
+	for name := range builtins {
+		if !tested[name] {
+			t.Errorf("built-in %s is not tested", name)
+		}
+	}
+}
+
+func TestApproximateVariables(t *testing.T) {
+	resetState(t)
+	calculate(t, "x = sqrt(2)")
+	if got := calculate(t, "x^2"); got != "2 (approximate)" {
+		t.Errorf("x^2 = %s, want 2 (approximate)", got)
+	}
+	calculate(t, "y = 1/3")
+	if got := calculate(t, "y"); got != "1/3" {
+		t.Errorf("y = %s, want 1/3", got)
+	}
+	calculate(t, "x = 3")
+	if got := calculate(t, "x / 2"); got != "3/2" {
+		t.Errorf("x / 2 = %s, want 3/2 after an exact assignment", got)
+	}
+}
@@ -0,0 +1,134 @@
+package smart_calculator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenIdentifier
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+	tokenAssign
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// pos is the column of the first character, counted from zero in runes.
+	pos int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// calcError is an error found at a column of the input line. If it happened in the body
+// of a user function, function names it and pos is the column of the call on the line.
+type calcError struct {
+	pos      int
+	msg      string
+	function string
+}
+
+func (e *calcError) Error() string {
+	return e.msg
+}
+
+func errorAt(pos int, format string, args ...any) *calcError {
+	return &calcError{pos, fmt.Sprintf(format, args...), ""}
+}
+
+// lex splits the line into tokens, ending with a tokenEnd. Numbers are integer, decimal
+// or scientific literals, identifiers start with a letter.
+func lex(line string) ([]token, error) {
+	runes := []rune(line)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case isDigit(r) || r == '.':
+			i = scanNumber(runes, i)
+			if i == start {
+				return nil, errorAt(start, "Unexpected character %q", r)
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start})
+			continue
+		case unicode.IsLetter(r):
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || isDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdentifier, string(runes[start:i]), start})
+			continue
+		case strings.ContainsRune("+-*/%^", r) && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{tokenAssign, string(runes[i : i+2]), start})
+			i++
+		case strings.ContainsRune("+-*/%^", r):
+			tokens = append(tokens, token{tokenOperator, string(r), start})
+		case r == '=':
+			tokens = append(tokens, token{tokenAssign, "=", start})
+		case r == '(':
+			tokens = append(tokens, token{tokenLeftParen, "(", start})
+		case r == ')':
+			tokens = append(tokens, token{tokenRightParen, ")", start})
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", start})
+		default:
+			return nil, errorAt(start, "Unexpected character %q", r)
+		}
+		i++
+	}
+	return append(tokens, token{tokenEnd, "", len(runes)}), nil
+}
+
+// scanNumber returns the end of the number literal starting at i, or i if there is none.
+// The exponent is only taken when digits follow, so 2e is the number 2 and the identifier e.
+func scanNumber(runes []rune, i int) int {
+	start := i
+	digits := 0
+	for i < len(runes) && isDigit(runes[i]) {
+		i++
+		digits++
+	}
+	if i < len(runes) && runes[i] == '.' {
+		i++
+		for i < len(runes) && isDigit(runes[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return start
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		j := i + 1
+		if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+			j++
+		}
+		if j < len(runes) && isDigit(runes[j]) {
+			for j < len(runes) && isDigit(runes[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
@@ -0,0 +1,59 @@
+package smart_calculator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		line string
+		want []token
+	}{
+		{"", []token{{tokenEnd, "", 0}}},
+		{"1 + 2", []token{{tokenNumber, "1", 0}, {tokenOperator, "+", 2}, {tokenNumber, "2", 4}, {tokenEnd, "", 5}}},
+		{"  a2=.5", []token{{tokenIdentifier, "a2", 2}, {tokenAssign, "=", 4}, {tokenNumber, ".5", 5}, {tokenEnd, "", 7}}},
+		{"x^=1.5e-3", []token{{tokenIdentifier, "x", 0}, {tokenAssign, "^=", 1}, {tokenNumber, "1.5e-3", 3}, {tokenEnd, "", 9}}},
+		{"2e", []token{{tokenNumber, "2", 0}, {tokenIdentifier, "e", 1}, {tokenEnd, "", 2}}},
+		{"max(a, 2)", []token{
+			{tokenIdentifier, "max", 0}, {tokenLeftParen, "(", 3}, {tokenIdentifier, "a", 4},
+			{tokenComma, ",", 5}, {tokenNumber, "2", 7}, {tokenRightParen, ")", 8}, {tokenEnd, "", 9},
+		}},
+		// Columns count runes, not bytes.
+		{"π * 2", []token{{tokenIdentifier, "π", 0}, {tokenOperator, "*", 2}, {tokenNumber, "2", 4}, {tokenEnd, "", 5}}},
+	}
+	for _, test := range tests {
+		got, err := lex(test.line)
+		if err != nil {
+			t.Errorf("lex(%q): %v", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("lex(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		pos  int
+		msg  string
+	}{
+		{"2 $ 3", 2, `Unexpected character '$'`},
+		{"1 + .", 4, `Unexpected character '.'`},
+		{"é # 1", 2, `Unexpected character '#'`},
+	}
+	for _, test := range tests {
+		_, err := lex(test.line)
+		var calcErr *calcError
+		if !errors.As(err, &calcErr) {
+			t.Errorf("lex(%q) error = %v, want a calcError", test.line, err)
+			continue
+		}
+		if calcErr.pos != test.pos || calcErr.msg != test.msg {
+			t.Errorf("lex(%q) error at %d %q, want at %d %q", test.line, calcErr.pos, calcErr.msg, test.pos, test.msg)
+		}
+	}
+}
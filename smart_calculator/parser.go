@@ -0,0 +1,264 @@
+package smart_calculator
+
+import (
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// node is an element of the syntax tree of an expression.
+type node interface {
+	// pos is the column errors about the node point at.
+	pos() int
+}
+
+type numberNode struct {
+	value *big.Rat
+	at    int
+}
+
+type variableNode struct {
+	name string
+	at   int
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+	at      int
+}
+
+type callNode struct {
+	name string
+	args []node
+	at   int
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+	at          int
+}
+
+// assignNode stores the value in the variable. The op is = or a compound operator like +=.
+type assignNode struct {
+	name   string
+	op     string
+	value  node
+	at     int
+	nameAt int
+}
+
+func (n numberNode) pos() int   { return n.at }
+func (n variableNode) pos() int { return n.at }
+func (n unaryNode) pos() int    { return n.at }
+func (n callNode) pos() int     { return n.at }
+func (n binaryNode) pos() int   { return n.at }
+func (n assignNode) pos() int   { return n.at }
+
+// Binding powers of the operators, from the loosest to the tightest. Unary plus and minus
+// bind tighter than products but looser than powers, so -2^2 is -(2^2).
+const (
+	precedenceLowest = iota
+	precedenceSum
+	precedenceProduct
+	precedenceUnary
+	precedencePower
+)
+
+type operator struct {
+	precedence       int
+	rightAssociative bool
+}
+
+var binaryOperators = map[string]operator{
+	"+": {precedenceSum, false},
+	"-": {precedenceSum, false},
+	"*": {precedenceProduct, false},
+	"/": {precedenceProduct, false},
+	"%": {precedenceProduct, false},
+	"^": {precedencePower, true},
+}
+
+var unaryOperators = map[string]operator{
+	"+": {precedenceUnary, true},
+	"-": {precedenceUnary, true},
+}
+
+func binaryOperator(t token) (operator, bool) {
+	if t.kind != tokenOperator {
+		return operator{}, false
+	}
+	op, ok := binaryOperators[t.text]
+	return op, ok
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+// parse builds the syntax tree of the expression on the whole line with a Pratt parser.
+func parse(line string) (node, error) {
+	return parseWith(line, (*parser).expressionStatement)
+}
+
+// parseStatement is parse that also accepts assignments like a = b = 2 or a += 1.
+func parseStatement(line string) (node, error) {
+	return parseWith(line, (*parser).statement)
+}
+
+func parseWith(line string, rule func(*parser) (node, error)) (node, error) {
+	tokens, err := lex(line)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	result, err := rule(p)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, errorAt(t.pos, "Unexpected %s", t)
+	}
+	return result, nil
+}
+
+// statement is an expression or an assignment, whose value may be another assignment.
+func (p *parser) statement() (node, error) {
+	if p.peek().kind == tokenIdentifier && p.tokens[p.next+1].kind == tokenAssign {
+		name := p.advance()
+		if err := checkIdentifier(name); err != nil {
+			return nil, err
+		}
+		op := p.advance()
+		value, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		return assignNode{name.text, op.text, value, op.pos, name.pos}, nil
+	}
+	return p.expressionStatement()
+}
+
+func (p *parser) expressionStatement() (node, error) {
+	expr, err := p.expression(precedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokenAssign {
+		return nil, errorAt(t.pos, invalidAssignment)
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+// expression parses operators binding tighter than precedence. The right operand of
+// a left-associative operator only takes operators binding tighter than its own, the
+// right operand of a right-associative one takes its own as well, so 2^3^2 is 2^(3^2).
+func (p *parser) expression(precedence int) (node, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := binaryOperator(p.peek())
+		if !ok || op.precedence <= precedence {
+			return left, nil
+		}
+		t := p.advance()
+		next := op.precedence
+		if op.rightAssociative {
+			next--
+		}
+		right, err := p.expression(next)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{t.text, left, right, t.pos}
+	}
+}
+
+func (p *parser) prefix() (node, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokenNumber:
+		value, err := parseNumber(t.text)
+		if err != nil {
+			return nil, errorAt(t.pos, "%s", err)
+		}
+		return numberNode{value, t.pos}, nil
+	case tokenIdentifier:
+		if err := checkIdentifier(t); err != nil {
+			return nil, err
+		}
+		if p.peek().kind == tokenLeftParen {
+			return p.call(t)
+		}
+		return variableNode{t.text, t.pos}, nil
+	case tokenOperator:
+		op, ok := unaryOperators[t.text]
+		if !ok {
+			break
+		}
+		operand, err := p.expression(op.precedence)
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{t.text, operand, t.pos}, nil
+	case tokenLeftParen:
+		expr, err := p.expression(precedenceLowest)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenRightParen {
+			return nil, errorAt(closing.pos, "Missing \")\" for the parenthesis opened at column %d", t.pos+1)
+		}
+		return expr, nil
+	}
+	return nil, errorAt(t.pos, "Unexpected %s", t)
+}
+
+// call parses the parenthesized arguments of the function called name.
+func (p *parser) call(name token) (node, error) {
+	open := p.advance()
+	var args []node
+	if p.peek().kind == tokenRightParen {
+		p.advance()
+		return callNode{name.text, args, name.pos}, nil
+	}
+	for {
+		arg, err := p.expression(precedenceLowest)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		switch t := p.advance(); t.kind {
+		case tokenRightParen:
+			return callNode{name.text, args, name.pos}, nil
+		case tokenComma:
+		default:
+			return nil, errorAt(t.pos, "Missing \")\" for the call of %s opened at column %d", name.text, open.pos+1)
+		}
+	}
+}
+
+// checkIdentifier rejects names with digits, which the lexer reads as one identifier
+// only to report them as invalid.
+func checkIdentifier(t token) error {
+	if strings.IndexFunc(t.text, unicode.IsDigit) >= 0 {
+		return errorAt(t.pos, invalidIdentifier)
+	}
+	return nil
+}
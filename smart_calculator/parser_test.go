@@ -0,0 +1,64 @@
+package smart_calculator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		pos  int
+		msg  string
+	}{
+		{"2 $ 3", 2, `Unexpected character '$'`},
+		{"2 ** 3", 3, `Unexpected "*"`},
+		{"1 +", 3, `Unexpected end of expression`},
+		{"1 2", 2, `Unexpected "2"`},
+		{"(1 + 2", 6, `Missing ")" for the parenthesis opened at column 1`},
+		{"2 * (3 + (4)", 12, `Missing ")" for the parenthesis opened at column 5`},
+		{"max(1, 2", 8, `Missing ")" for the call of max opened at column 4`},
+		{"1 + 2)", 5, `Unexpected ")"`},
+		{"a2 + 1", 0, invalidIdentifier},
+		{"1 = 2", 2, invalidAssignment},
+		{"a = 3 +", 7, `Unexpected end of expression`},
+		{"a = b == 2", 7, `Unexpected "="`},
+	}
+	for _, test := range tests {
+		_, err := parseStatement(test.line)
+		var calcErr *calcError
+		if !errors.As(err, &calcErr) {
+			t.Errorf("parseStatement(%q) error = %v, want a calcError", test.line, err)
+			continue
+		}
+		if calcErr.pos != test.pos || calcErr.msg != test.msg {
+			t.Errorf("parseStatement(%q) error at %d %q, want at %d %q", test.line, calcErr.pos, calcErr.msg, test.pos, test.msg)
+		}
+	}
+}
+
+func TestParseRejectsAssignments(t *testing.T) {
+	_, err := parse("a = 1")
+	var calcErr *calcError
+	if !errors.As(err, &calcErr) || calcErr.pos != 2 || calcErr.msg != invalidAssignment {
+		t.Errorf("parse(%q) error = %v, want %q at 2", "a = 1", err, invalidAssignment)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		line string
+		err  error
+		want string
+	}{
+		{"2 $ 3", errorAt(2, "Unexpected character '$'"), "2 $ 3\n  ^\nColumn 3: Unexpected character '$'\n"},
+		{"π + x", errorAt(4, unknownVariable), "π + x\n    ^\nColumn 5: Unknown variable\n"},
+		{"1 + f(0)", &calcError{4, divisionByZero, "f"}, "1 + f(0)\n    ^\nColumn 5: In function f: Division by zero\n"},
+		{"/load", errors.New("no such file"), "no such file\n"},
+	}
+	for _, test := range tests {
+		if got := formatError(test.line, test.err); got != test.want {
+			t.Errorf("formatError(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
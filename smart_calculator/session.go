@@ -0,0 +1,173 @@
+package smart_calculator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+)
+
+const sessionHeader = "# Smart Calculator session: mode, variables and functions"
+
+// approximateMarker ends the saved assignments of approximate values, so they are
+// shown as decimals again once loaded.
+const approximateMarker = "# approximate"
+
+// autosaveFile is where the session is saved after every change, autosave is off if empty.
+var autosaveFile string
+
+// saveSession writes the mode, variables and functions as calculator input, one per line,
+// so the file can be read, edited and shared. Values are exact fractions, followed by
+// approximateMarker for values that came from float64 results.
+func saveSession(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, sessionHeader)
+	fmt.Fprintf(w, "/mode %s\n", mode)
+	for _, key := range sortedKeys(memory) {
+		if approximations[key] {
+			fmt.Fprintf(w, "%s = %s %s\n", key, memory[key].RatString(), approximateMarker)
+		} else {
+			fmt.Fprintf(w, "%s = %s\n", key, memory[key].RatString())
+		}
+	}
+	for _, key := range sortedKeys(functions) {
+		fmt.Fprintf(w, "%s%s\n", key, functions[key])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadSession runs the assignments and function definitions of the file in order,
+// skipping blank lines and # comments, and switches to the mode of its /mode line.
+// It stops at the first line that fails and leaves the session as it was before the load.
+func loadSession(name string) (err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Values are saved as exact fractions and function bodies may hold decimals, so the
+	// file is read in rational mode and the values are then adapted to the loaded mode.
+	current, loaded := mode, mode
+	savedMemory, savedFunctions, savedApproximations := maps.Clone(memory), maps.Clone(functions), maps.Clone(approximations)
+	mode = rationalMode
+	defer func() {
+		if err != nil {
+			memory, functions, approximations = savedMemory, savedFunctions, savedApproximations
+			mode = current
+			return
+		}
+		mode = loaded
+		if mode == intMode {
+			truncateMemory()
+		}
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fields := strings.Fields(line); fields[0] == "/mode" {
+			if len(fields) != 2 || !isMode(fields[1]) {
+				return fmt.Errorf("%s:%d: unknown mode %q", name, number, strings.Join(fields[1:], " "))
+			}
+			loaded = fields[1]
+			continue
+		}
+		line, marked := strings.CutSuffix(line, approximateMarker)
+		if err := loadLine(strings.TrimSpace(line), marked); err != nil {
+			var calcErr *calcError
+			if errors.As(err, &calcErr) {
+				return fmt.Errorf("%s:%d:%d: %s", name, number, calcErr.pos+1, calcErr.msg)
+			}
+			return fmt.Errorf("%s:%d: %s", name, number, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// loadLine runs one assignment or definition, marking an assigned value as approximate
+// if the line had approximateMarker.
+func loadLine(line string, marked bool) error {
+	approximate = marked
+	if isDefinition(line) {
+		return define(line)
+	}
+	stmt, err := parseStatement(line)
+	if err != nil {
+		return err
+	}
+	if _, ok := stmt.(assignNode); !ok {
+		return errors.New("only assignments and function definitions can be loaded")
+	}
+	_, err = evaluate(stmt)
+	return err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sessionCommand handles /save file, /load file and /autosave file|off.
+func sessionCommand(str string) {
+	fields := strings.Fields(str)
+	if len(fields) == 1 && fields[0] == "/autosave" {
+		if autosaveFile == "" {
+			fmt.Println("Autosave is off")
+		} else {
+			fmt.Printf("Autosave to %s\n", autosaveFile)
+		}
+		return
+	}
+	if len(fields) != 2 {
+		fmt.Printf("Use %s file\n", fields[0])
+		return
+	}
+	var err error
+	switch fields[0] {
+	case "/save":
+		err = saveSession(fields[1])
+	case "/load":
+		if err = loadSession(fields[1]); err == nil {
+			autosave()
+		}
+	case "/autosave":
+		if fields[1] == "off" {
+			autosaveFile = ""
+			return
+		}
+		autosaveFile = fields[1]
+		err = saveSession(autosaveFile)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// autosave saves the session if autosave is on, reporting rather than stopping on errors.
+func autosave() {
+	if autosaveFile == "" {
+		return
+	}
+	if err := saveSession(autosaveFile); err != nil {
+		fmt.Printf("Autosave failed: %s\n", err)
+	}
+}
@@ -0,0 +1,77 @@
+package smart_calculator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionKeepsModeAndValues(t *testing.T) {
+	resetState(t)
+	name := filepath.Join(t.TempDir(), "session.calc")
+	calculate(t, "x = 1/3")
+	if err := define("h(x) = x * 0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSession(name); err != nil {
+		t.Fatal(err)
+	}
+
+	resetState(t)
+	mode = intMode
+	if err := loadSession(name); err != nil {
+		t.Fatal(err)
+	}
+	if mode != rationalMode {
+		t.Errorf("mode is %s after the load, want %s", mode, rationalMode)
+	}
+	for line, want := range map[string]string{"x": "1/3", "h(3)": "3/2"} {
+		if got := calculate(t, line); got != want {
+			t.Errorf("%s = %s, want %s", line, got, want)
+		}
+	}
+}
+
+func TestSessionKeepsApproximations(t *testing.T) {
+	resetState(t)
+	name := filepath.Join(t.TempDir(), "session.calc")
+	calculate(t, "x = sqrt(2)")
+	calculate(t, "y = 1/3")
+	if err := saveSession(name); err != nil {
+		t.Fatal(err)
+	}
+
+	resetState(t)
+	if err := loadSession(name); err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[string]string{"x": "1.4142135623731 (approximate)", "y": "1/3"} {
+		if got := calculate(t, line); got != want {
+			t.Errorf("%s = %s, want %s", line, got, want)
+		}
+	}
+}
+
+func TestFailedLoadKeepsSession(t *testing.T) {
+	resetState(t)
+	name := filepath.Join(t.TempDir(), "broken.calc")
+	content := "/mode float\na = 5\nf(x) = x + 1\nb = a +\n"
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	calculate(t, "a = 1")
+
+	err := loadSession(name)
+	if err == nil || err.Error() != name+":4:8: Unexpected end of expression" {
+		t.Fatalf("loadSession error = %v", err)
+	}
+	if mode != rationalMode {
+		t.Errorf("mode is %s after a failed load, want %s", mode, rationalMode)
+	}
+	if got := calculate(t, "a"); got != "1" {
+		t.Errorf("a = %s after a failed load, want 1", got)
+	}
+	if _, ok := functions["f"]; ok {
+		t.Error("f is defined after a failed load")
+	}
+}
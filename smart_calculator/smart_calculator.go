@@ -2,23 +2,47 @@ package smart_calculator
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"regexp"
-	"strconv"
 	"strings"
-	"unicode"
 )
 
 const (
-	about             = "The program calculates a sum(+), subtraction(-), multiplication(*) and division(-) of numbers supporting parenthesis"
+	about = "The program calculates a sum(+), subtraction(-), multiplication(*), division(/), modulo(%) and power(^) of numbers supporting parenthesis.\n" +
+		"Numbers may be decimal (1.5) or scientific (2e10). /mode int|rational|float chooses how numbers are calculated and shown.\n" +
+		"The default mode is rational, so 7/2 is 7/2. Earlier versions divided integers, use /mode int to get 7/2 = 3.\n" +
+		"Define functions like f(x, y) = x^2 + y, list them with /funcs and remove a function or variable with /delete name.\n" +
+		"/save file and /load file keep the mode, variables and functions in a file, /autosave file|off saves them after every change.\n" +
+		"Results without an exact value, like sqrt(2), are shown as approximate decimals in rational mode.\n" +
+		"Built-in functions: "
 	invalidAssignment = "Invalid assignment"
 	invalidIdentifier = "Invalid identifier"
 	unknownVariable   = "Unknown variable"
 	invalidExpression = "Invalid expression"
+	divisionByZero    = "Division by zero"
+	notAnInteger      = "Decimal numbers need /mode rational or /mode float"
 )
 
-var memory = make(map[string]int)
+// Numeric models chosen with /mode. Values are always exact rationals: int mode keeps them
+// integer by truncating division, float mode only prints them as decimals.
+const (
+	intMode      = "int"
+	rationalMode = "rational"
+	floatMode    = "float"
+)
+
+var (
+	memory = make(map[string]*big.Rat)
+	// approximations holds the variables whose value came from a float64 result.
+	approximations = make(map[string]bool)
+	// approximate is set while evaluating a line that uses a float64 result.
+	approximate   bool
+	mode          = rationalMode
+	numberLiteral = regexp.MustCompile(`^(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+)
 
 func Run() {
 
@@ -31,174 +55,136 @@ func Run() {
 			fmt.Println("Bye!")
 			return
 		case strings.Contains(str, "/help"):
-			fmt.Println(about)
+			fmt.Println(about + builtinNames())
+		case strings.HasPrefix(str, "/mode"):
+			setMode(str)
+			autosave()
+		case str == "/funcs":
+			listFunctions()
+		case strings.HasPrefix(str, "/delete"):
+			deleteName(str)
+			autosave()
+		case strings.HasPrefix(str, "/save"), strings.HasPrefix(str, "/load"), strings.HasPrefix(str, "/autosave"):
+			sessionCommand(str)
 		case str == "":
 		case strings.HasPrefix(str, "/"):
 			fmt.Println("Unknown command")
-		case strings.ContainsAny(str, "+-/*()"):
-			postfixString, err := infixToPostfix(str)
-			result, err := calculate(postfixString)
-			if err != nil {
-				fmt.Println(invalidExpression)
+		case isDefinition(str):
+			if err := define(str); err != nil {
+				printError(str, err)
 			} else {
-				fmt.Println(result)
+				autosave()
 			}
-		case strings.IndexFunc(str, unicode.IsLetter) == 0:
-			processVariables(str)
 		default:
-			fmt.Println(invalidExpression)
+			if calculateLine(str) {
+				autosave()
+			}
 		}
 	}
 }
 
-func calculate(postfix []string) (int, error) {
-	var stack []int
-	for _, token := range postfix {
-		switch token {
-		case "+":
-			if len(stack) < 2 {
-				return 0, fmt.Errorf("invalid expression")
-			}
-			b := stack[len(stack)-1]
-			a := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
-			stack = append(stack, a+b)
-		case "-":
-			if len(stack) < 2 {
-				return 0, fmt.Errorf("invalid expression")
-			}
-			b := stack[len(stack)-1]
-			a := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
-			stack = append(stack, a-b)
-		case "*":
-			if len(stack) < 2 {
-				return 0, fmt.Errorf("invalid expression")
-			}
-			b := stack[len(stack)-1]
-			a := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
-			stack = append(stack, a*b)
-		case "/":
-			if len(stack) < 2 {
-				return 0, fmt.Errorf("invalid expression")
-			}
-			b := stack[len(stack)-1]
-			a := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
-			stack = append(stack, a/b)
-		default:
-			num, err := resolve(token)
-			if err != nil {
-				return 0, err
-			}
-			stack = append(stack, num)
-		}
+// calculateLine evaluates the expression and prints the result, or performs the assignment
+// and reports it changed the variables. Errors are printed with a caret under the column
+// where they were found.
+func calculateLine(line string) bool {
+	approximate = false
+	expr, err := parseStatement(line)
+	if err != nil {
+		printError(line, err)
+		return false
+	}
+	result, err := evaluate(expr)
+	if err != nil {
+		printError(line, err)
+		return false
 	}
-	if len(stack) != 1 {
-		return 0, fmt.Errorf("invalid expression")
+	if _, ok := expr.(assignNode); ok {
+		return true
 	}
-	return stack[0], nil
+	fmt.Println(formatNumber(result))
+	return false
 }
 
-func infixToPostfix(str string) ([]string, error) {
-	str = strings.ReplaceAll(str, " ", "")
-	re := regexp.MustCompile(`\d+|[a-zA-Z]+|\+|\-|\*|\/|\(|\)`)
-	tokens := re.FindAllString(str, -1)
+func printError(line string, err error) {
+	fmt.Print(formatError(line, err))
+}
 
-	var stack []string
-	var postfix []string
+// formatError shows errors found at a column as the line with a caret under the column.
+func formatError(line string, err error) string {
+	var calcErr *calcError
+	if !errors.As(err, &calcErr) {
+		return err.Error() + "\n"
+	}
+	caret := line + "\n" + strings.Repeat(" ", calcErr.pos) + "^\n"
+	if calcErr.function != "" {
+		return caret + fmt.Sprintf("Column %d: In function %s: %s\n", calcErr.pos+1, calcErr.function, calcErr.msg)
+	}
+	return caret + fmt.Sprintf("Column %d: %s\n", calcErr.pos+1, calcErr.msg)
+}
 
-	for i, token := range tokens {
-		switch token {
-		case "+", "-":
-			if i == 0 || tokens[i-1] == "(" || tokens[i-1] == "+" || tokens[i-1] == "-" || tokens[i-1] == "*" || tokens[i-1] == "/" {
-				// Unary plus or minus
-				postfix = append(postfix, "0") // Append a zero to the postfix
-				stack = append(stack, token)   // And treat the unary operator as a binary one
-			} else {
-				// Binary plus or minus
-				for len(stack) > 0 && (stack[len(stack)-1] == "+" || stack[len(stack)-1] == "-" || stack[len(stack)-1] == "*" || stack[len(stack)-1] == "/") {
-					postfix = append(postfix, stack[len(stack)-1])
-					stack = stack[:len(stack)-1]
-				}
-				stack = append(stack, token)
-			}
-		case "*", "/":
-			for len(stack) > 0 && (stack[len(stack)-1] == "*" || stack[len(stack)-1] == "/") {
-				postfix = append(postfix, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
-			}
-			stack = append(stack, token)
-		case "(":
-			stack = append(stack, token)
-		case ")":
-			for len(stack) > 0 && stack[len(stack)-1] != "(" {
-				postfix = append(postfix, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
-			}
-			if len(stack) > 0 && stack[len(stack)-1] == "(" {
-				stack = stack[:len(stack)-1]
-			} else {
-				return nil, fmt.Errorf(invalidExpression)
-			}
-		default:
-			postfix = append(postfix, token)
+func resolve(name string) (*big.Rat, error) {
+	if val, ok := memory[name]; ok {
+		if approximations[name] {
+			approximate = true
 		}
+		return val, nil
 	}
+	return nil, fmt.Errorf(unknownVariable)
+}
 
-	for len(stack) > 0 {
-		postfix = append(postfix, stack[len(stack)-1])
-		stack = stack[:len(stack)-1]
+// parseNumber reads an integer, decimal or scientific literal like 12, 0.5 or 1.5e-3.
+// In int mode only literals with an integer value are accepted.
+func parseNumber(str string) (*big.Rat, error) {
+	if !numberLiteral.MatchString(str) {
+		return nil, fmt.Errorf(invalidExpression)
 	}
-
-	return postfix, nil
+	num, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return nil, fmt.Errorf(invalidExpression)
+	}
+	if mode == intMode && !num.IsInt() {
+		return nil, fmt.Errorf(notAnInteger)
+	}
+	return num, nil
 }
 
-func resolve(str string) (int, error) {
-	if strings.IndexFunc(str, unicode.IsLetter) == 0 {
-		if val, ok := memory[str]; ok {
-			return val, nil
-		}
-		return 0, fmt.Errorf(unknownVariable)
-	} else {
-		return strconv.Atoi(str)
+// formatNumber prints num the way the current mode shows numbers. Float mode shows
+// 15 significant digits, which float64 results like 2^0.5 are accurate to. Rational mode
+// shows approximate results the same way, with a note.
+func formatNumber(num *big.Rat) string {
+	decimal := new(big.Float).SetPrec(128).SetRat(num).Text('g', 15)
+	switch {
+	case mode == floatMode:
+		return decimal
+	case mode == rationalMode && approximate:
+		return decimal + " (approximate)"
+	default:
+		return num.RatString()
 	}
 }
 
-func processVariables(str string) {
-	str = strings.Replace(str, " ", "", -1)
-	fields := strings.Split(str, "=")
-	length := len(fields)
-	if length < 1 || length > 2 {
-		fmt.Println(invalidAssignment)
-		return
-	}
-	matched, err := regexp.MatchString("^[a-zA-Z]+$", fields[0])
-	if err != nil || matched == false {
-		fmt.Println(invalidIdentifier)
-		return
+func isMode(name string) bool {
+	return name == intMode || name == rationalMode || name == floatMode
+}
+
+// truncateMemory truncates stored values the same way int mode truncates division results.
+func truncateMemory() {
+	for key, val := range memory {
+		memory[key] = truncate(new(big.Rat).Set(val))
 	}
-	switch length {
-	case 1:
-		if val, ok := memory[fields[0]]; ok {
-			fmt.Println(val)
-		} else {
-			fmt.Println(unknownVariable)
-		}
-		return
-	case 2:
-		key := fields[0]
-		value, err := strconv.Atoi(fields[1])
-		if err != nil {
-			if val, ok := memory[fields[1]]; ok {
-				memory[key] = val
-			} else {
-				fmt.Println(invalidIdentifier)
-			}
-			return
-		} else {
-			memory[key] = value
+}
+
+func setMode(str string) {
+	fields := strings.Fields(str)
+	switch {
+	case len(fields) == 1:
+		fmt.Println(mode)
+	case len(fields) == 2 && isMode(fields[1]):
+		mode = fields[1]
+		if mode == intMode {
+			truncateMemory()
 		}
+	default:
+		fmt.Println("Unknown mode, use /mode int, /mode rational or /mode float")
 	}
 }